@@ -3,9 +3,9 @@ package blame
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
@@ -17,68 +17,163 @@ import (
 
 var log = logging.MustGetLogger("listme")
 
-// Maximum length for the Git author string
+// Maximum length for the Git author string shown next to matches, see TruncateName
 const MaxAuthorLength = 20
 
 // LineBlame contains Git blame information for a specific file line.
-//   - Time: date and time of commit
+//   - Time: author date and time of commit
 //   - Author: author name
+//   - Email: author email
+//   - CommitterTime: committer date and time of commit
+//   - Committer: committer name
+//   - CommitterEmail: committer email
+//   - Commit: hash of the commit that introduced the line
+//   - Summary: first line of the commit message
 type LineBlame struct {
-	Time   time.Time
-	Author string
+	Time           time.Time
+	CommitterTime  time.Time
+	Author         string
+	Email          string
+	Committer      string
+	CommitterEmail string
+	Commit         string
+	Summary        string
 }
 
+// Maximum number of line ranges passed to a single git blame call.
+// Files with more ranges are blamed entirely.
+const maxLineRanges = 256
+
+// GitBlame contains Git blame information for the lines of a file.
 type GitBlame struct {
-	blames []*LineBlame
+	blames map[int]*LineBlame
+}
+
+// NewGitBlame returns a GitBlame with the blames of consecutive lines, starting at line 1.
+func NewGitBlame(blames []*LineBlame) *GitBlame {
+	gb := &GitBlame{blames: make(map[int]*LineBlame, len(blames))}
+	for i, lineBlame := range blames {
+		if lineBlame != nil {
+			gb.blames[i+1] = lineBlame
+		}
+	}
+	return gb
 }
 
 // BlameLine returns a LineBlame for the specified line if possible.
-// If the line is out of range, an error is returned.
+// If the line was not blamed, an error is returned.
 func (b *GitBlame) BlameLine(line int) (*LineBlame, error) {
-	line = line - 1
-	if line < 0 || line >= len(b.blames) {
-		err := fmt.Errorf("line %d out of range", line)
+	lineBlame, ok := b.blames[line]
+	if !ok {
+		err := fmt.Errorf("no blame for line %d", line)
 		log.Info(err)
 		return nil, err
 	}
-	return b.blames[line], nil
+	return lineBlame, nil
 }
 
-func parseGitBlame(out io.Reader) []*LineBlame {
-	var blames []*LineBlame
+// parseGitBlame parses the output of git blame --line-porcelain into blames
+// by final line number.
+func parseGitBlame(out io.Reader) map[int]*LineBlame {
+	blames := make(map[int]*LineBlame)
 	lr := bufio.NewReader(out)
 	s := bufio.NewScanner(lr)
 
 	var currentBlame *LineBlame
 	for s.Scan() {
 		buf := s.Text()
-		if strings.HasPrefix(buf, "author ") {
-			if currentBlame != nil {
-				blames = append(blames, currentBlame)
-			}
-			currentBlame = &LineBlame{
-				Author: truncateName(strings.TrimPrefix(buf, "author "), MaxAuthorLength),
-			}
-		} else if strings.HasPrefix(buf, "author-time ") {
-			if currentBlame != nil {
-				tsStr := strings.TrimPrefix(buf, "author-time ")
-				ts, err := strconv.ParseInt(tsStr, 10, 64)
-				time := time.Unix(ts, 0)
-				if err == nil {
-					currentBlame.Time = time
-				}
-			}
+		if strings.HasPrefix(buf, "\t") {
+			// line contents
+			continue
+		}
+		if line, ok := parseHeader(buf); ok {
+			currentBlame = &LineBlame{Commit: strings.Fields(buf)[0]}
+			blames[line] = currentBlame
+			continue
 		}
+		if currentBlame == nil {
+			continue
+		}
+		key, value, _ := strings.Cut(buf, " ")
+		switch key {
+		case "author":
+			currentBlame.Author = value
+		case "author-mail":
+			currentBlame.Email = parseEmail(value)
+		case "author-time":
+			currentBlame.Time = parseTime(value)
+		case "committer":
+			currentBlame.Committer = value
+		case "committer-mail":
+			currentBlame.CommitterEmail = parseEmail(value)
+		case "committer-time":
+			currentBlame.CommitterTime = parseTime(value)
+		case "summary":
+			currentBlame.Summary = value
+		}
+	}
+	return blames
+}
+
+// parseEmail removes the angle brackets around emails in git blame output.
+func parseEmail(value string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+}
+
+// parseTime parses a Unix timestamp, returning the zero time if it's invalid.
+func parseTime(value string) time.Time {
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
 	}
+	return time.Unix(ts, 0)
+}
 
-	// Append the last entry
-	if currentBlame != nil {
-		blames = append(blames, currentBlame)
+// parseHeader parses the "<sha> <orig line> <final line> [<lines in group>]" header
+// that starts each line entry and returns the final line number.
+func parseHeader(buf string) (int, bool) {
+	fields := strings.Fields(buf)
+	if len(fields) < 3 || len(fields) > 4 || !isHash(fields[0]) {
+		return 0, false
 	}
-	return blames
+	line, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, false
+	}
+	return line, true
 }
 
-func truncateName(name string, maxLength int) string {
+func isHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// lineRanges converts sorted line numbers into git blame -L arguments,
+// merging consecutive lines into a single range.
+func lineRanges(lines []int) []string {
+	var args []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] <= lines[j]+1 {
+			j++
+		}
+		args = append(args, "-L", fmt.Sprintf("%d,%d", lines[i], lines[j]))
+		i = j + 1
+	}
+	return args
+}
+
+// TruncateName shortens a name to maxLength for display by abbreviating its words
+// to their initials, starting from the last one, e.g. Alice Margaret Example to
+// Alice M E with a maxLength of 16.
+func TruncateName(name string, maxLength int) string {
 	totalLen := len(name)
 	words := strings.Fields(name) // Split the name into words
 
@@ -104,20 +199,55 @@ func truncateName(name string, maxLength int) string {
 	return strings.Join(truncated, " ")
 }
 
-// BlameFile runs git blame for the provided path using the OS interface,
-// parses the output and returns a *GitBlame or error.
-func BlameFile(path string) (*GitBlame, error) {
+// Blamer returns Git blame information for files.
+// repoDir is the directory git runs in, any directory inside the repository of path.
+// lines are the sorted 1-based line numbers to blame, or nil to blame the whole file.
+// Implementations must be safe for concurrent use.
+type Blamer interface {
+	BlameFile(ctx context.Context, repoDir string, path string, lines []int) (*GitBlame, error)
+}
+
+// GitBlamer is a Blamer that runs the git executable.
+// It never changes the working directory of the process.
+//
+// Authors are mapped with the .mailmap of the repository. Revisions listed in the file
+// configured with blame.ignoreRevsFile, or else in .git-blame-ignore-revs at the root
+// of the repository, are ignored. The zero value is ready to use.
+type GitBlamer struct {
+	repos repositories
+}
+
+// NewGitBlamer returns a GitBlamer.
+func NewGitBlamer() *GitBlamer {
+	return &GitBlamer{}
+}
+
+// BlameFile runs git blame in repoDir for the provided lines of path using the OS interface,
+// parses the output and returns a *GitBlame or error. All lines are blamed in a single
+// git call with one -L range per group of consecutive lines.
+// The git process is killed if ctx is cancelled before it finishes.
+func (g *GitBlamer) BlameFile(ctx context.Context, repoDir string, path string, lines []int) (*GitBlame, error) {
 	absolutePath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, err
 	}
 
-	err = os.Chdir(filepath.Dir(absolutePath))
-	if err != nil {
-		return nil, err
+	args := []string{"blame", "--line-porcelain"}
+	// run git at the root of the repository so that relative paths in its config resolve
+	dir := repoDir
+	if repo, err := g.repos.get(ctx, repoDir); err == nil {
+		dir = repo.root
+		if repo.ignoreRevsFile != "" {
+			args = append(args, "--ignore-revs-file", repo.ignoreRevsFile)
+		}
+	}
+	if ranges := lineRanges(lines); len(ranges) <= 2*maxLineRanges {
+		args = append(args, ranges...)
 	}
+	args = append(args, "--", absolutePath)
 
-	cmd := exec.Command("git", "blame", absolutePath, "--line-porcelain")
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -140,3 +270,13 @@ func BlameFile(path string) (*GitBlame, error) {
 
 	return &GitBlame{blames: blames}, nil
 }
+
+// BlameFile runs git blame for all lines of the provided path in the directory of the file.
+// See GitBlamer.BlameFile.
+func BlameFile(ctx context.Context, path string) (*GitBlame, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewGitBlamer().BlameFile(ctx, filepath.Dir(absolutePath), absolutePath, nil)
+}
@@ -0,0 +1,67 @@
+package blame
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const porcelain = `1111111111111111111111111111111111111111 3 3 1
+author Alice Margaret Example-Longname
+author-mail <alice@example.com>
+author-time 1700000000
+committer Bob
+committer-mail <bob@example.com>
+committer-time 1700000100
+summary Add the first TODO
+filename main.go
+	// TODO: first
+2222222222222222222222222222222222222222 7 9 1
+author Bob
+author-time 1600000000
+filename main.go
+	2222222222222222222222222222222222222222 1 1 1
+`
+
+func TestTruncateName(t *testing.T) {
+	tests := map[string]string{
+		"Bob":                        "Bob",
+		"Alice Margaret Example":     "Alice M E",
+		"Alice Margaret Example Doe": "Alice M E D",
+		"alice.margaret@example.com": "alice.margaret@e",
+	}
+	for name, expected := range tests {
+		if truncated := TruncateName(name, 16); truncated != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, truncated)
+		}
+	}
+}
+
+func TestParseGitBlame(t *testing.T) {
+	gb := &GitBlame{blames: parseGitBlame(strings.NewReader(porcelain))}
+	if len(gb.blames) != 2 {
+		t.Fatalf("expected 2 blamed lines, got %d", len(gb.blames))
+	}
+	if b, err := gb.BlameLine(3); err != nil || b.Author != "Alice Margaret Example-Longname" || b.Time.Unix() != 1700000000 {
+		t.Errorf("unexpected blame for line 3: %+v, %v", b, err)
+	}
+	if b, _ := gb.BlameLine(3); b.Email != "alice@example.com" || b.Committer != "Bob" ||
+		b.CommitterEmail != "bob@example.com" || b.CommitterTime.Unix() != 1700000100 ||
+		b.Commit != strings.Repeat("1", 40) || b.Summary != "Add the first TODO" {
+		t.Errorf("unexpected commit metadata for line 3: %+v", b)
+	}
+	if b, err := gb.BlameLine(9); err != nil || b.Author != "Bob" {
+		t.Errorf("unexpected blame for line 9: %+v, %v", b, err)
+	}
+	if _, err := gb.BlameLine(1); err == nil {
+		t.Error("expected an error for a line that was not blamed")
+	}
+}
+
+func TestLineRanges(t *testing.T) {
+	got := lineRanges([]int{1, 2, 3, 7, 9, 10})
+	want := []string{"-L", "1,3", "-L", "7,7", "-L", "9,10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,324 @@
+package blame
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version of the cache format. Changing it invalidates all cache entries.
+const cacheVersion = "3"
+
+// DefaultCacheMaxAge is the default time after which unused cache entries are pruned.
+const DefaultCacheMaxAge = 30 * 24 * time.Hour
+
+// The cache directory is scanned for old entries at most once per pruneInterval.
+// The time of the last scan is the modification time of pruneMarker.
+const pruneInterval = 24 * time.Hour
+const pruneMarker = "last-prune"
+
+// CachedBlamer is a Blamer that stores the blames of committed files on disk, keyed
+// by repository, path, blob hash and the ignore revs and mailmap files, so that
+// unchanged files are not blamed again on later runs. Files with uncommitted changes
+// and untracked files are never cached. Use Prune to remove unused entries.
+type CachedBlamer struct {
+	blamer Blamer
+	dir    string
+	roots  *repositories
+
+	mu    sync.Mutex
+	repos map[string]*repoIndex
+}
+
+// repoIndex has the blob hashes of the files of a repository that matched HEAD
+// when it was read. Files changed since then are detected by hashing them, see key.
+type repoIndex struct {
+	mu    sync.Mutex
+	done  bool
+	blobs map[string]string
+	err   error
+}
+
+// cacheEntry contains the blamed lines of a file.
+// Complete is true if all lines of the file were blamed.
+type cacheEntry struct {
+	Lines    map[int]*LineBlame `json:"lines"`
+	Complete bool               `json:"complete"`
+}
+
+// NewCachedBlamer returns a CachedBlamer that stores cache entries in dir and
+// calls blamer on cache misses. If blamer is a GitBlamer, both share the
+// repositories they find, so git is not asked twice for the same settings.
+func NewCachedBlamer(blamer Blamer, dir string) *CachedBlamer {
+	roots := &repositories{}
+	if g, ok := blamer.(*GitBlamer); ok {
+		roots = &g.repos
+	}
+	return &CachedBlamer{
+		blamer: blamer,
+		dir:    dir,
+		roots:  roots,
+		repos:  make(map[string]*repoIndex),
+	}
+}
+
+// DefaultCacheDir returns the default blame cache directory,
+// $XDG_CACHE_HOME/listme/blame on Linux.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "listme", "blame"), nil
+}
+
+// BlameFile returns the cached blames of path if all requested lines are cached.
+// Otherwise, the missing lines are blamed and added to the cache.
+func (c *CachedBlamer) BlameFile(ctx context.Context, repoDir string, path string, lines []int) (*GitBlame, error) {
+	key, ok := c.key(ctx, repoDir, path)
+	if !ok {
+		return c.blamer.BlameFile(ctx, repoDir, path, lines)
+	}
+
+	entry := c.load(key)
+	missing := entry.missing(lines)
+	if entry.Complete || (lines != nil && len(missing) == 0) {
+		log.Debugf("blame cache hit for %s", path)
+		return &GitBlame{blames: entry.Lines}, nil
+	}
+
+	gb, err := c.blamer.BlameFile(ctx, repoDir, path, missing)
+	if err != nil {
+		return nil, err
+	}
+	for line, lineBlame := range gb.blames {
+		entry.Lines[line] = lineBlame
+	}
+	entry.Complete = lines == nil
+	if err := c.store(key, entry); err != nil {
+		log.Warningf("failed to write blame cache for %s: %s", path, err)
+	}
+	return &GitBlame{blames: entry.Lines}, nil
+}
+
+// missing returns the lines that are not in the entry, or nil if all lines were requested.
+func (e *cacheEntry) missing(lines []int) []int {
+	var missing []int
+	for _, line := range lines {
+		if _, ok := e.Lines[line]; !ok {
+			missing = append(missing, line)
+		}
+	}
+	return missing
+}
+
+// key returns the cache key of path. If path is untracked, has uncommitted changes
+// or is not in a Git repository, it returns false.
+func (c *CachedBlamer) key(ctx context.Context, repoDir string, path string) (string, bool) {
+	repo, err := c.roots.get(ctx, repoDir)
+	if err != nil {
+		log.Debugf("blame cache disabled for %s: %s", path, err)
+		return "", false
+	}
+	root := repo.root
+	blobs, err := c.index(ctx, root)
+	if err != nil {
+		log.Debugf("blame cache disabled for %s: %s", path, err)
+		return "", false
+	}
+
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	if resolved, err := filepath.EvalSymlinks(absolutePath); err == nil {
+		absolutePath = resolved
+	}
+	rel, err := filepath.Rel(root, absolutePath)
+	if err != nil {
+		return "", false
+	}
+	blob, ok := blobs[filepath.ToSlash(rel)]
+	if !ok {
+		return "", false
+	}
+	// the file may have changed since the index was read by an earlier search
+	if hash, err := blobHash(absolutePath, len(blob)); err != nil || hash != blob {
+		log.Debugf("blame cache disabled for %s: changed since the index was read", path)
+		return "", false
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{cacheVersion, root, filepath.ToSlash(rel), blob, repo.digest}, "\x00")))
+	return hex.EncodeToString(sum[:]), true
+}
+
+func (c *CachedBlamer) index(ctx context.Context, root string) (map[string]string, error) {
+	c.mu.Lock()
+	idx, ok := c.repos[root]
+	if !ok {
+		idx = &repoIndex{}
+		c.repos[root] = idx
+	}
+	c.mu.Unlock()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.done {
+		blobs, err := cleanBlobs(ctx, root)
+		if err != nil && ctx.Err() != nil {
+			// a cancelled search must not disable the cache for later ones
+			return nil, err
+		}
+		idx.blobs, idx.err, idx.done = blobs, err, true
+	}
+	return idx.blobs, idx.err
+}
+
+// blobHash returns the Git blob hash of the file at path, as git hash-object would
+// without filters: SHA-1, or SHA-256 if hexLen is that of a SHA-256 hash.
+func blobHash(path string, hexLen int) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	if hexLen == 2*sha256.Size {
+		h = sha256.New()
+	}
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cleanBlobs returns the blob hashes of the tracked files of the repository at root
+// by path relative to root, leaving out files that differ from HEAD.
+func cleanBlobs(ctx context.Context, root string) (map[string]string, error) {
+	out, err := runGit(ctx, root, "ls-files", "-s", "-z")
+	if err != nil {
+		return nil, err
+	}
+	blobs := make(map[string]string)
+	for _, record := range strings.Split(string(out), "\x00") {
+		// <mode> <blob> <stage>\t<path>
+		info, path, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 || fields[2] != "0" {
+			continue
+		}
+		blobs[path] = fields[1]
+	}
+
+	out, err = runGit(ctx, root, "diff", "--name-only", "-z", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(string(out), "\x00") {
+		delete(blobs, path)
+	}
+	return blobs, nil
+}
+
+func (c *CachedBlamer) load(key string) *cacheEntry {
+	entry := &cacheEntry{}
+	path := filepath.Join(c.dir, key+".json")
+	content, err := os.ReadFile(path)
+	if err == nil {
+		// the modification time is the last use of the entry, see Prune
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		if err := json.Unmarshal(content, entry); err != nil {
+			log.Debugf("ignoring invalid blame cache entry %s: %s", key, err)
+			entry = &cacheEntry{}
+		}
+	}
+	if entry.Lines == nil {
+		entry.Lines = make(map[int]*LineBlame)
+	}
+	return entry
+}
+
+// store writes the entry to a temporary file first, so that concurrent
+// readers never see a partially written entry.
+func (c *CachedBlamer) store(key string, entry *cacheEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, key+".json"))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Prune removes the cache entries that were not used within maxAge, as well as
+// temporary files left behind by interrupted runs. To keep it cheap, the cache
+// directory is scanned at most once a day and later calls return immediately.
+func (c *CachedBlamer) Prune(maxAge time.Duration) error {
+	now := time.Now()
+	marker := filepath.Join(c.dir, pruneMarker)
+	if info, err := os.Stat(marker); err == nil && now.Sub(info.ModTime()) < pruneInterval {
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var removed int
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || (!strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".tmp")) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, name)); err == nil {
+			removed++
+		}
+	}
+	log.Debugf("pruned %d blame cache entries older than %s", removed, maxAge)
+	return os.WriteFile(marker, nil, 0o644)
+}
+
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v - %s", args[0], err, stderr.String())
+	}
+	return out, nil
+}
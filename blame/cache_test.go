@@ -0,0 +1,164 @@
+package blame
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingBlamer struct {
+	calls atomic.Int32
+}
+
+func (b *countingBlamer) BlameFile(ctx context.Context, repoDir string, path string, lines []int) (*GitBlame, error) {
+	b.calls.Add(1)
+	return NewGitBlame([]*LineBlame{{Author: "alice"}, {Author: "bob"}}), nil
+}
+
+// newTestRepo creates a Git repository with a commit by alice adding a.go with content.
+func newTestRepo(t *testing.T, content string) string {
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "a.go"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = repo
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("git not available: %s %s", err, out)
+	}
+	commitAll(t, repo, "alice <alice@example.com>", "init")
+	return repo
+}
+
+func commitAll(t *testing.T, repo string, author string, message string) {
+	name, email, _ := strings.Cut(strings.TrimSuffix(author, ">"), " <")
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=" + name, "-c", "user.email=" + email, "commit", "-q", "-m", message},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %s %s", args[0], err, out)
+		}
+	}
+}
+
+func TestCachedBlamer(t *testing.T) {
+	repo := newTestRepo(t, "// TODO: one\n// TODO: two\n")
+	path := filepath.Join(repo, "a.go")
+
+	ctx := context.Background()
+	inner := &countingBlamer{}
+	cacheDir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		blamer := NewCachedBlamer(inner, cacheDir)
+		gb, err := blamer.BlameFile(ctx, repo, path, []int{2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := gb.BlameLine(2); err != nil || b.Author != "bob" {
+			t.Errorf("unexpected blame for line 2: %+v, %v", b, err)
+		}
+	}
+	if calls := inner.calls.Load(); calls != 1 {
+		t.Errorf("expected 1 blame call for an unchanged file, got %d", calls)
+	}
+
+	if err := os.WriteFile(path, []byte("// TODO: changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCachedBlamer(inner, cacheDir).BlameFile(ctx, repo, path, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := inner.calls.Load(); calls != 2 {
+		t.Errorf("expected files with uncommitted changes to be blamed, got %d calls", calls)
+	}
+}
+
+func TestCachedBlamerReused(t *testing.T) {
+	repo := newTestRepo(t, "// TODO: one\n// TODO: two\n")
+	path := filepath.Join(repo, "a.go")
+	inner := &countingBlamer{}
+	blamer := NewCachedBlamer(inner, t.TempDir())
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	blamer.BlameFile(cancelled, repo, path, []int{2})
+
+	// the cancelled search must not disable the cache
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := blamer.BlameFile(ctx, repo, path, []int{2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := inner.calls.Load(); calls != 2 {
+		t.Errorf("expected a cache hit after the cancelled search, got %d blame calls", calls)
+	}
+
+	// a file edited after the index was read must not be blamed from the cache
+	if err := os.WriteFile(path, []byte("// TODO: new\n// TODO: one\n// TODO: two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blamer.BlameFile(ctx, repo, path, []int{2}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := inner.calls.Load(); calls != 3 {
+		t.Errorf("expected the edited file to be blamed, got %d blame calls", calls)
+	}
+}
+
+func TestBlobHash(t *testing.T) {
+	repo := newTestRepo(t, "// TODO: one\n")
+	cmd := exec.Command("git", "hash-object", "a.go")
+	cmd.Dir = repo
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.TrimSpace(string(out))
+	if hash, err := blobHash(filepath.Join(repo, "a.go"), len(expected)); err != nil || hash != expected {
+		t.Errorf("expected %s, got %s, %v", expected, hash, err)
+	}
+}
+
+func TestCachedBlamerPrune(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * DefaultCacheMaxAge)
+	for _, name := range []string{"old.json", "new.json", "old.json.1.tmp"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(name, "old") {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	c := NewCachedBlamer(NewGitBlamer(), dir)
+	if err := c.Prune(DefaultCacheMaxAge); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "last-prune,new.json" {
+		t.Errorf("unexpected cache entries after pruning: %v", names)
+	}
+	if c.roots != &c.blamer.(*GitBlamer).repos {
+		t.Error("expected the repositories to be shared with the GitBlamer")
+	}
+}
@@ -0,0 +1,165 @@
+package blame
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Name of the file listing the revisions ignored by git blame when blame.ignoreRevsFile
+// is not configured, following the convention of GitHub and GitLab.
+const defaultIgnoreRevsFile = ".git-blame-ignore-revs"
+
+// repository has the settings of a Git repository that change the output of git blame.
+//   - root: top level directory of the repository
+//   - ignoreRevsFile: file to pass with --ignore-revs-file, empty if git reads
+//     it from blame.ignoreRevsFile or if there's none
+//   - digest: hash of the ignore revs and mailmap files, to invalidate cached blames
+type repository struct {
+	root           string
+	ignoreRevsFile string
+	digest         string
+}
+
+// repositoryEntry is the repository of a top level directory, opened on first use.
+type repositoryEntry struct {
+	mu   sync.Mutex
+	done bool
+	repo *repository
+	err  error
+}
+
+// repositories finds the repository of directories. The top level directory is found
+// by looking for .git on the filesystem, so git runs at most once per repository.
+// The zero value is ready to use.
+type repositories struct {
+	mu     sync.Mutex
+	roots  map[string]string
+	byRoot map[string]*repositoryEntry
+}
+
+func (r *repositories) get(ctx context.Context, dir string) (*repository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.byRoot == nil {
+		r.roots = make(map[string]string)
+		r.byRoot = make(map[string]*repositoryEntry)
+	}
+	root := r.findRoot(dir)
+	if root == "" {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("not a git repository: %s", dir)
+	}
+	entry, ok := r.byRoot[root]
+	if !ok {
+		entry = &repositoryEntry{}
+		r.byRoot[root] = entry
+	}
+	r.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !entry.done {
+		repo, err := openRepository(ctx, root)
+		if ctx.Err() != nil {
+			// settings read while ctx was cancelled may be missing, so open it again next time
+			return nil, ctx.Err()
+		}
+		entry.repo, entry.err, entry.done = repo, err, true
+	}
+	return entry.repo, entry.err
+}
+
+// findRoot returns the closest directory containing .git, a folder or a file in the
+// case of worktrees and submodules, or an empty string if there's none.
+// The result is remembered for every directory on the way. r.mu must be held.
+func (r *repositories) findRoot(dir string) string {
+	var visited []string
+	root := ""
+	for d := dir; ; d = filepath.Dir(d) {
+		if cached, ok := r.roots[d]; ok {
+			root = cached
+			break
+		}
+		visited = append(visited, d)
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			root = d
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	for _, d := range visited {
+		r.roots[d] = root
+	}
+	return root
+}
+
+func openRepository(ctx context.Context, dir string) (*repository, error) {
+	out, err := runGit(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	repo := &repository{root: filepath.Clean(strings.TrimSpace(string(out)))}
+
+	// git reads blame.ignoreRevsFile by itself, relative to the directory it runs in
+	revsFile := gitConfig(ctx, repo.root, "blame.ignoreRevsFile")
+	if revsFile == "" {
+		revsFile = filepath.Join(repo.root, defaultIgnoreRevsFile)
+		if _, err := os.Stat(revsFile); err == nil {
+			repo.ignoreRevsFile = revsFile
+		}
+	}
+
+	mailmapFile := gitConfig(ctx, repo.root, "mailmap.file")
+	h := sha256.New()
+	for _, name := range []string{revsFile, filepath.Join(repo.root, ".mailmap"), mailmapFile} {
+		if name == "" {
+			continue
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(repo.root, name)
+		}
+		content, _ := os.ReadFile(name)
+		h.Write([]byte(name + "\x00"))
+		h.Write(content)
+		h.Write([]byte("\x00"))
+	}
+	repo.digest = hex.EncodeToString(h.Sum(nil))
+	return repo, nil
+}
+
+// gitConfig returns the value of a Git config key, or an empty string if it's not set.
+func gitConfig(ctx context.Context, dir string, key string) string {
+	out, err := runGit(ctx, dir, "config", "--get", key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// CanonicalEmail maps email to the canonical email of its author according to the
+// .mailmap of the repository containing dir. If it can't be mapped, email is returned.
+func CanonicalEmail(ctx context.Context, dir string, email string) string {
+	out, err := runGit(ctx, dir, "check-mailmap", "<"+email+">")
+	if err != nil {
+		log.Debugf("failed to resolve %s with .mailmap: %s", email, err)
+		return email
+	}
+	mapped := strings.TrimSpace(string(out))
+	start, end := strings.LastIndex(mapped, "<"), strings.LastIndex(mapped, ">")
+	if start < 0 || end < start {
+		return email
+	}
+	return mapped[start+1 : end]
+}
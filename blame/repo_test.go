@@ -0,0 +1,86 @@
+package blame
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitBlamerIgnoreRevsAndMailmap(t *testing.T) {
+	repo := newTestRepo(t, "// TODO: one\n")
+	path := filepath.Join(repo, "a.go")
+	if err := os.WriteFile(path, []byte("//   TODO: one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	commitAll(t, repo, "formatter <formatter@example.com>", "reformat")
+
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = repo
+	rev, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		defaultIgnoreRevsFile: string(rev),
+		".mailmap":            "Alice Canonical <alice@canonical.com> <alice@example.com>\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gb, err := NewGitBlamer().BlameFile(context.Background(), repo, path, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := gb.BlameLine(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Author != "Alice Canonical" || b.Email != "alice@canonical.com" || strings.HasPrefix(string(rev), b.Commit) {
+		t.Errorf("expected the reformat commit to be ignored and alice to be mapped, got %+v", b)
+	}
+
+	if email := CanonicalEmail(context.Background(), repo, "alice@example.com"); email != "alice@canonical.com" {
+		t.Errorf("expected the canonical email, got %s", email)
+	}
+}
+
+func TestRepositoriesByRoot(t *testing.T) {
+	repo := newTestRepo(t, "// TODO: one\n")
+	sub := filepath.Join(repo, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var repos repositories
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := repos.get(cancelled, repo); err == nil {
+		t.Error("expected an error with a cancelled context")
+	}
+
+	// the cancelled lookup must not be remembered
+	ctx := context.Background()
+	first, err := repos.get(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repos.get(ctx, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("expected directories of the same repository to share the repository")
+	}
+	if len(repos.byRoot) != 1 {
+		t.Errorf("expected a single repository lookup, got %d", len(repos.byRoot))
+	}
+	if _, err := repos.get(ctx, t.TempDir()); err == nil {
+		t.Error("expected an error outside of a repository")
+	}
+}
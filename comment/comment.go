@@ -0,0 +1,292 @@
+package comment
+
+import (
+	"bytes"
+	"sort"
+	"unicode/utf8"
+)
+
+// Delimiters of a block comment or a string literal.
+type Delimiters struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// StringSyntax describes a string literal.
+//   - Escape: a backslash escapes the next character
+//   - Multiline: the string may span several lines
+//   - Char: the literal holds a single character or escape sequence, e.g. Rust's '"'.
+//     The start delimiter is ordinary text if the literal isn't closed right after it,
+//     as in Rust's lifetimes: &'a str
+type StringSyntax struct {
+	Delimiters
+	Escape    bool `json:"escape"`
+	Multiline bool `json:"multiline"`
+	Char      bool `json:"char"`
+}
+
+// Region is a part of a file written in another language, e.g. a script in HTML.
+// It ends at the first end delimiter, even inside a string of the other language.
+type Region struct {
+	Delimiters
+	Syntax *Syntax
+}
+
+// Syntax describes the comments and string literals of a language.
+//   - Name: name of the language
+//   - Line: prefixes of line comments, e.g. "//"
+//   - Block: delimiters of block comments, e.g. "/*" and "*/"
+//   - Nested: block comments can be nested
+//   - Strings: string literals, whose contents are never comments
+//   - Regions: parts of the file in another language
+type Syntax struct {
+	Name    string
+	Line    []string
+	Block   []Delimiters
+	Strings []StringSyntax
+	Regions []Region
+	Nested  bool
+}
+
+// Segment is the part of a line inside a comment, without the comment delimiters.
+//   - Text: comment text
+//   - Column: 1-based byte column where Text starts
+//   - Block: the segment is part of a block comment
+//   - Continued: the segment continues a block comment from the previous line
+//   - Open: the block comment continues on the next line
+type Segment struct {
+	Text      string
+	Column    int
+	Block     bool
+	Continued bool
+	Open      bool
+}
+
+type tokenKind int
+
+const (
+	lineToken tokenKind = iota
+	blockToken
+	stringToken
+	regionToken
+)
+
+type token struct {
+	start string
+	kind  tokenKind
+	index int
+}
+
+// Lexer finds the comments of a file, one line at a time. It keeps track of block
+// comments and strings that span several lines, so lines must be passed in order.
+type Lexer struct {
+	syntax *Syntax
+	tokens []token
+	first  [256]bool
+
+	block  *Delimiters
+	depth  int
+	str    *StringSyntax
+	region *Region
+	inner  *Lexer
+}
+
+// NewLexer returns a Lexer for the syntax.
+func NewLexer(syntax *Syntax) *Lexer {
+	lx := &Lexer{syntax: syntax}
+	for _, prefix := range syntax.Line {
+		lx.tokens = append(lx.tokens, token{start: prefix, kind: lineToken})
+	}
+	for i, block := range syntax.Block {
+		lx.tokens = append(lx.tokens, token{start: block.Start, kind: blockToken, index: i})
+	}
+	for i, str := range syntax.Strings {
+		lx.tokens = append(lx.tokens, token{start: str.Start, kind: stringToken, index: i})
+	}
+	for i, region := range syntax.Regions {
+		lx.tokens = append(lx.tokens, token{start: region.Start, kind: regionToken, index: i})
+	}
+	// the longest token wins, e.g. Lua's --[[ over --
+	sort.SliceStable(lx.tokens, func(i, j int) bool {
+		return len(lx.tokens[i].start) > len(lx.tokens[j].start)
+	})
+	for _, t := range lx.tokens {
+		if t.start != "" {
+			lx.first[t.start[0]] = true
+		}
+	}
+	return lx
+}
+
+// IsLineComment returns true if a line comment starts after prefix, with nothing
+// but whitespace before it.
+func (lx *Lexer) IsLineComment(prefix []byte) bool {
+	prefix = bytes.TrimLeft(prefix, " \t")
+	if lx.inner != nil && lx.inner.IsLineComment(prefix) {
+		return true
+	}
+	for _, p := range lx.syntax.Line {
+		if string(prefix) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the comment segments of the next line of the file.
+func (lx *Lexer) Next(line []byte) []Segment {
+	if lx.inner != nil && len(line) == 0 {
+		return lx.inner.Next(line)
+	}
+	var segments []Segment
+	continued := lx.block != nil
+	for i := 0; i < len(line); {
+		switch {
+		case lx.inner != nil:
+			rest := line[i:]
+			end := bytes.Index(rest, []byte(lx.region.End))
+			if end >= 0 {
+				rest = rest[:end]
+			}
+			for _, segment := range lx.inner.Next(rest) {
+				segment.Column += i
+				segments = append(segments, segment)
+			}
+			if end < 0 {
+				return segments
+			}
+			i += end + len(lx.region.End)
+			lx.inner = nil
+			lx.region = nil
+
+		case lx.block != nil:
+			end, closed := lx.blockEnd(line, i)
+			segments = append(segments, Segment{
+				Text:      string(line[i:end]),
+				Column:    i + 1,
+				Block:     true,
+				Continued: continued,
+				Open:      !closed,
+			})
+			if !closed {
+				return segments
+			}
+			i = end + len(lx.block.End)
+			lx.block = nil
+			continued = false
+
+		case lx.str != nil:
+			end, closed := lx.stringEnd(line, i)
+			if !closed {
+				if !lx.str.Multiline {
+					lx.str = nil
+				}
+				return segments
+			}
+			i = end + len(lx.str.End)
+			lx.str = nil
+
+		default:
+			t, ok := lx.tokenAt(line, i)
+			if !ok {
+				i++
+				continue
+			}
+			i += len(t.start)
+			switch t.kind {
+			case lineToken:
+				return append(segments, Segment{Text: string(line[i:]), Column: i + 1})
+			case blockToken:
+				lx.block = &lx.syntax.Block[t.index]
+				lx.depth = 1
+			case stringToken:
+				str := &lx.syntax.Strings[t.index]
+				if !str.Char {
+					lx.str = str
+				} else if end, ok := charEnd(line, i, str.End); ok {
+					i = end + len(str.End)
+				}
+			case regionToken:
+				lx.region = &lx.syntax.Regions[t.index]
+				lx.inner = NewLexer(lx.region.Syntax)
+			}
+		}
+	}
+	if lx.block != nil {
+		// the block comment starts at the end of the line or the line is empty
+		segments = append(segments, Segment{Column: len(line) + 1, Block: true, Continued: continued, Open: true})
+	}
+	if lx.str != nil && !lx.str.Multiline {
+		lx.str = nil
+	}
+	return segments
+}
+
+func (lx *Lexer) tokenAt(line []byte, i int) (token, bool) {
+	if !lx.first[line[i]] {
+		return token{}, false
+	}
+	for _, t := range lx.tokens {
+		if t.start != "" && bytes.HasPrefix(line[i:], []byte(t.start)) {
+			return t, true
+		}
+	}
+	return token{}, false
+}
+
+// blockEnd returns the position where the current block comment ends, or the end
+// of the line if it's not closed on this line.
+func (lx *Lexer) blockEnd(line []byte, i int) (int, bool) {
+	for ; i < len(line); i++ {
+		rest := line[i:]
+		if lx.syntax.Nested && bytes.HasPrefix(rest, []byte(lx.block.Start)) {
+			lx.depth++
+			i += len(lx.block.Start) - 1
+			continue
+		}
+		if bytes.HasPrefix(rest, []byte(lx.block.End)) {
+			lx.depth--
+			if lx.depth == 0 {
+				return i, true
+			}
+			i += len(lx.block.End) - 1
+		}
+	}
+	return len(line), false
+}
+
+// charEnd returns the position of the end delimiter of a character literal starting at i,
+// that is, after a single character or an escape sequence such as \n or \u{1F600}.
+func charEnd(line []byte, i int, end string) (int, bool) {
+	if i >= len(line) {
+		return 0, false
+	}
+	if line[i] == '\\' {
+		// the longest escape sequence is \u{10FFFF}
+		for j := i + 2; j < len(line) && j <= i+10; j++ {
+			if bytes.HasPrefix(line[j:], []byte(end)) {
+				return j, true
+			}
+		}
+		return 0, false
+	}
+	_, size := utf8.DecodeRune(line[i:])
+	if bytes.HasPrefix(line[i+size:], []byte(end)) {
+		return i + size, true
+	}
+	return 0, false
+}
+
+// stringEnd returns the position where the current string ends.
+func (lx *Lexer) stringEnd(line []byte, i int) (int, bool) {
+	for ; i < len(line); i++ {
+		if lx.str.Escape && line[i] == '\\' {
+			i++
+			continue
+		}
+		if bytes.HasPrefix(line[i:], []byte(lx.str.End)) {
+			return i, true
+		}
+	}
+	return len(line), false
+}
@@ -0,0 +1,105 @@
+package comment
+
+import (
+	"strings"
+	"testing"
+)
+
+// comments returns the comment text of each line of src, joined with "|".
+func comments(syntax *Syntax, src string) []string {
+	lexer := NewLexer(syntax)
+	var result []string
+	for _, line := range strings.Split(src, "\n") {
+		var texts []string
+		for _, segment := range lexer.Next([]byte(line)) {
+			texts = append(texts, segment.Text)
+		}
+		result = append(result, strings.Join(texts, "|"))
+	}
+	return result
+}
+
+func TestLexer(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		want     []string
+	}{
+		{
+			name:     "go strings",
+			language: "go",
+			src:      "s := \"// TODO: no\" // TODO: yes\nr := '\"' // quote\nraw := `\n// not a comment\n`",
+			want:     []string{" TODO: yes", " quote", "", "", ""},
+		},
+		{
+			name:     "c block",
+			language: "c",
+			src:      "a /* one */ b /* two\nthree\n*/ c // four",
+			want:     []string{" one | two", "three", "| four"},
+		},
+		{
+			name:     "python",
+			language: "python",
+			src:      "x = '# no'  # yes\n\"\"\"\nTODO: docstring\n\"\"\"",
+			want:     []string{" yes", "", "TODO: docstring", ""},
+		},
+		{
+			name:     "lua",
+			language: "lua",
+			src:      "--[[ block\nTODO ]] x = 1 -- line",
+			want:     []string{" block", "TODO | line"},
+		},
+		{
+			name:     "rust char literals",
+			language: "rust",
+			src:      "let q = '\"'; // a\n// TODO: after char literal\nfn f<'a>(x: &'a str) {} // lifetime\nlet c = '\\''; // escaped",
+			want:     []string{" a", " TODO: after char literal", " lifetime", " escaped"},
+		},
+		{
+			name:     "html regions",
+			language: "html",
+			src:      "{#if x}<p>it's // not</p>\n<script>\nlet s = \"</p>\" // TODO: js\n/* a\nb */</script><!-- c -->\n<style>/* d */</style>",
+			want:     []string{"", "", " TODO: js", " a", "b | c ", " d "},
+		},
+		{
+			name:     "haskell nested",
+			language: "haskell",
+			src:      "{- a {- b -} c -} x -- d",
+			want:     []string{" a {- b -} c | d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := comments(languages[tt.language], tt.src)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		path      string
+		firstLine string
+		want      string
+	}{
+		{"main.go", "package main", "go"},
+		{"dir/Makefile", "all:", "hash"},
+		{"script", "#!/usr/bin/env -S python3 -u", "python"},
+		{"run", "#!/bin/bash", "shell"},
+		{"App.svelte", "<script>", "html"},
+		{"notes.txt", "TODO: something", ""},
+	}
+	for _, tt := range tests {
+		syntax := Detect(tt.path, []byte(tt.firstLine))
+		var got string
+		if syntax != nil {
+			got = syntax.Name
+		}
+		if got != tt.want {
+			t.Errorf("Detect(%s): expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
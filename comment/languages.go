@@ -0,0 +1,149 @@
+package comment
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	cBlock        = Delimiters{Start: "/*", End: "*/"}
+	htmlBlock     = Delimiters{Start: "<!--", End: "-->"}
+	doubleQuoted  = StringSyntax{Delimiters: Delimiters{Start: `"`, End: `"`}, Escape: true}
+	singleQuoted  = StringSyntax{Delimiters: Delimiters{Start: "'", End: "'"}, Escape: true}
+	backtick      = StringSyntax{Delimiters: Delimiters{Start: "`", End: "`"}, Multiline: true}
+	backtickEsc   = StringSyntax{Delimiters: Delimiters{Start: "`", End: "`"}, Escape: true, Multiline: true}
+	tripleDouble  = StringSyntax{Delimiters: Delimiters{Start: `"""`, End: `"""`}, Escape: true, Multiline: true}
+	rawSingle     = StringSyntax{Delimiters: Delimiters{Start: "'", End: "'"}}
+	multiDouble   = StringSyntax{Delimiters: Delimiters{Start: `"`, End: `"`}, Escape: true, Multiline: true}
+	rustChar      = StringSyntax{Delimiters: Delimiters{Start: "'", End: "'"}, Char: true}
+	cStrings      = []StringSyntax{doubleQuoted, singleQuoted}
+	scriptStrings = []StringSyntax{multiDouble, rawSingle}
+)
+
+// javascript and css are also used for the scripts and styles of HTML.
+var (
+	javascript = &Syntax{Name: "javascript", Line: []string{"//"}, Block: []Delimiters{cBlock}, Strings: []StringSyntax{doubleQuoted, singleQuoted, backtickEsc}}
+	css        = &Syntax{Name: "css", Block: []Delimiters{cBlock}, Strings: cStrings}
+	html       = &Syntax{Name: "html", Block: []Delimiters{htmlBlock}, Regions: []Region{
+		{Delimiters: Delimiters{Start: "<script", End: "</script>"}, Syntax: javascript},
+		{Delimiters: Delimiters{Start: "<style", End: "</style>"}, Syntax: css},
+	}}
+)
+
+// languages are the built-in comment syntaxes by name. Python docstrings are treated
+// as block comments. The hash syntax has no strings because configuration files often
+// have apostrophes in unquoted text. HTML, which includes Vue and Svelte components,
+// only has line comments in scripts.
+var languages = map[string]*Syntax{
+	"c":          {Name: "c", Line: []string{"//"}, Block: []Delimiters{cBlock}, Strings: cStrings},
+	"go":         {Name: "go", Line: []string{"//"}, Block: []Delimiters{cBlock}, Strings: []StringSyntax{doubleQuoted, singleQuoted, backtick}},
+	"javascript": javascript,
+	"rust":       {Name: "rust", Line: []string{"//"}, Block: []Delimiters{cBlock}, Strings: []StringSyntax{multiDouble, rustChar}, Nested: true},
+	"swift":      {Name: "swift", Line: []string{"//"}, Block: []Delimiters{cBlock}, Strings: []StringSyntax{tripleDouble, doubleQuoted}, Nested: true},
+	"kotlin":     {Name: "kotlin", Line: []string{"//"}, Block: []Delimiters{cBlock}, Strings: []StringSyntax{tripleDouble, doubleQuoted, singleQuoted}, Nested: true},
+	"css":        css,
+	"php":        {Name: "php", Line: []string{"//", "#"}, Block: []Delimiters{cBlock}, Strings: cStrings},
+	"python":     {Name: "python", Line: []string{"#"}, Block: []Delimiters{{Start: `"""`, End: `"""`}, {Start: "'''", End: "'''"}}, Strings: cStrings},
+	"ruby":       {Name: "ruby", Line: []string{"#"}, Strings: []StringSyntax{multiDouble, {Delimiters: Delimiters{Start: "'", End: "'"}, Escape: true, Multiline: true}}},
+	"shell":      {Name: "shell", Line: []string{"#"}, Strings: scriptStrings},
+	"script":     {Name: "script", Line: []string{"#"}, Strings: []StringSyntax{doubleQuoted, rawSingle}},
+	"hash":       {Name: "hash", Line: []string{"#"}},
+	"sql":        {Name: "sql", Line: []string{"--"}, Block: []Delimiters{cBlock}, Strings: []StringSyntax{{Delimiters: Delimiters{Start: "'", End: "'"}, Multiline: true}}},
+	"lua":        {Name: "lua", Line: []string{"--"}, Block: []Delimiters{{Start: "--[[", End: "]]"}}, Strings: []StringSyntax{doubleQuoted, singleQuoted, {Delimiters: Delimiters{Start: "[[", End: "]]"}, Multiline: true}}},
+	"haskell":    {Name: "haskell", Line: []string{"--"}, Block: []Delimiters{{Start: "{-", End: "-}"}}, Strings: []StringSyntax{doubleQuoted}, Nested: true},
+	"html":       html,
+	"markup":     {Name: "markup", Line: []string{"//"}, Block: []Delimiters{htmlBlock, cBlock, {Start: "{{!--", End: "--}}"}, {Start: "{#", End: "#}"}}},
+	"lisp":       {Name: "lisp", Line: []string{";"}, Block: []Delimiters{{Start: "#|", End: "|#"}}, Strings: []StringSyntax{multiDouble}, Nested: true},
+	"erlang":     {Name: "erlang", Line: []string{"%"}, Strings: []StringSyntax{doubleQuoted}},
+	"tex":        {Name: "tex", Line: []string{"%"}},
+	"fortran":    {Name: "fortran", Line: []string{"!"}, Strings: cStrings},
+	"ini":        {Name: "ini", Line: []string{";", "#"}},
+}
+
+// extensions maps file extensions to language names.
+var extensions = map[string]string{
+	".c": "c", ".h": "c", ".cc": "c", ".cpp": "c", ".cxx": "c", ".hh": "c", ".hpp": "c",
+	".cs": "c", ".java": "c", ".scala": "c", ".groovy": "c", ".dart": "c", ".proto": "c",
+	".mm": "c", ".zig": "c", ".sol": "c",
+	".go": "go",
+	".js": "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+	".ts": "javascript", ".tsx": "javascript", ".mts": "javascript", ".cts": "javascript",
+	".rs":    "rust",
+	".swift": "swift",
+	".kt":    "kotlin", ".kts": "kotlin",
+	".css": "css", ".scss": "c", ".less": "c",
+	".php": "php",
+	".py":  "python", ".pyi": "python", ".pyw": "python",
+	".rb": "ruby", ".rake": "ruby", ".gemspec": "ruby",
+	".sh": "shell", ".bash": "shell", ".zsh": "shell", ".fish": "shell", ".ksh": "shell",
+	".pl": "script", ".pm": "script", ".r": "script", ".jl": "script", ".nim": "script", ".cr": "script",
+	".ex": "script", ".exs": "script", ".ps1": "script",
+	".tf": "hash", ".hcl": "hash", ".nix": "hash",
+	".yaml": "hash", ".yml": "hash", ".toml": "hash", ".cmake": "hash", ".mk": "hash",
+	".cfg": "ini", ".ini": "ini", ".conf": "hash", ".properties": "hash", ".dockerfile": "hash",
+	".sql": "sql",
+	".lua": "lua",
+	".hs":  "haskell", ".lhs": "haskell", ".elm": "haskell",
+	".html": "html", ".htm": "html", ".xml": "html", ".svg": "html", ".xhtml": "html",
+	".vue": "html", ".svelte": "html", ".hbs": "markup", ".handlebars": "markup",
+	".j2": "markup", ".jinja": "markup", ".jinja2": "markup", ".twig": "markup",
+	".lisp": "lisp", ".el": "lisp", ".clj": "lisp", ".cljs": "lisp", ".scm": "lisp", ".rkt": "lisp",
+	".erl": "erlang", ".hrl": "erlang",
+	".tex": "tex", ".sty": "tex", ".cls": "tex",
+	".f": "fortran", ".f90": "fortran", ".f95": "fortran", ".f03": "fortran", ".for": "fortran",
+}
+
+// filenames maps file names without a meaningful extension to language names.
+var filenames = map[string]string{
+	"Makefile":       "hash",
+	"makefile":       "hash",
+	"GNUmakefile":    "hash",
+	"Dockerfile":     "hash",
+	"Containerfile":  "hash",
+	"Rakefile":       "ruby",
+	"Gemfile":        "ruby",
+	"Vagrantfile":    "ruby",
+	"CMakeLists.txt": "hash",
+	".bashrc":        "shell",
+	".zshrc":         "shell",
+	".profile":       "shell",
+	".gitignore":     "hash",
+	".editorconfig":  "ini",
+}
+
+// interpreters maps shebang interpreters to language names.
+var interpreters = map[string]string{
+	"sh": "shell", "bash": "shell", "zsh": "shell", "ksh": "shell", "dash": "shell", "fish": "shell",
+	"python": "python", "python2": "python", "python3": "python",
+	"ruby": "ruby", "perl": "script", "Rscript": "script",
+	"node": "javascript", "deno": "javascript", "bun": "javascript",
+	"lua": "lua", "php": "php",
+}
+
+// Detect returns the built-in comment syntax for a file, see Registry.Detect.
+func Detect(path string, firstLine []byte) *Syntax {
+	return builtin.Detect(path, firstLine)
+}
+
+// shebangInterpreter returns the name of the interpreter of a shebang line,
+// e.g. python3 for "#!/usr/bin/env python3".
+func shebangInterpreter(line []byte) string {
+	if !bytes.HasPrefix(line, []byte("#!")) {
+		return ""
+	}
+	fields := strings.Fields(string(line[2:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				return field
+			}
+		}
+		return ""
+	}
+	return interpreter
+}
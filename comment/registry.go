@@ -0,0 +1,196 @@
+package comment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// builtin is the registry of the built-in languages.
+var builtin = NewRegistry()
+
+// Registry maps files to the comment syntax of their language.
+type Registry struct {
+	languages    map[string]*Syntax
+	extensions   map[string]string
+	filenames    map[string]string
+	interpreters map[string]string
+}
+
+// NewRegistry returns a registry of the built-in languages.
+func NewRegistry() *Registry {
+	r := &Registry{
+		languages:    make(map[string]*Syntax, len(languages)),
+		extensions:   make(map[string]string, len(extensions)),
+		filenames:    make(map[string]string, len(filenames)),
+		interpreters: make(map[string]string, len(interpreters)),
+	}
+	for name, syntax := range languages {
+		r.languages[name] = syntax
+	}
+	for ext, name := range extensions {
+		r.extensions[ext] = name
+	}
+	for file, name := range filenames {
+		r.filenames[file] = name
+	}
+	for interpreter, name := range interpreters {
+		r.interpreters[interpreter] = name
+	}
+	return r
+}
+
+// Detect returns the comment syntax for a file, chosen by its name or extension or,
+// failing that, by the interpreter in the shebang of its first line.
+// It returns nil if the language is unknown.
+func (r *Registry) Detect(path string, firstLine []byte) *Syntax {
+	base := filepath.Base(path)
+	if name, ok := r.filenames[base]; ok {
+		return r.languages[name]
+	}
+	if name, ok := r.extensions[strings.ToLower(filepath.Ext(base))]; ok {
+		return r.languages[name]
+	}
+	if name, ok := r.interpreters[shebangInterpreter(firstLine)]; ok {
+		return r.languages[name]
+	}
+	return nil
+}
+
+// languageConfig is a language in the configuration file. Fields that are set
+// replace those of the built-in language of the same name, if any.
+type languageConfig struct {
+	Line         []string       `json:"line"`
+	Block        []Delimiters   `json:"block"`
+	Strings      []StringSyntax `json:"strings"`
+	Nested       *bool          `json:"nested"`
+	Extensions   []string       `json:"extensions"`
+	Filenames    []string       `json:"filenames"`
+	Interpreters []string       `json:"interpreters"`
+}
+
+type config struct {
+	Languages map[string]*languageConfig `json:"languages"`
+}
+
+// DefaultConfigPath returns the default path of the languages configuration file,
+// $XDG_CONFIG_HOME/listme/languages.json on Linux.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "listme", "languages.json"), nil
+}
+
+// LoadRegistry returns a registry of the built-in languages extended with the
+// languages of a JSON configuration file, e.g.
+//
+//	{"languages": {"ocaml": {"block": [{"start": "(*", "end": "*)"}], "nested": true, "extensions": [".ml"]}}}
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRegistry()
+	if err := r.load(data); err != nil {
+		return nil, fmt.Errorf("invalid languages file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *Registry) load(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var c config
+	if err := dec.Decode(&c); err != nil {
+		return err
+	}
+
+	// sorted so that conflicting extensions are resolved the same way every time
+	names := make([]string, 0, len(c.Languages))
+	for name := range c.Languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := r.add(name, c.Languages[name]); err != nil {
+			return fmt.Errorf("language %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// add adds a language to the registry or overrides the built-in language of the same name.
+func (r *Registry) add(name string, lc *languageConfig) error {
+	if name == "" {
+		return fmt.Errorf("empty language name")
+	}
+	if lc == nil {
+		lc = &languageConfig{}
+	}
+
+	syntax := &Syntax{Name: name}
+	if builtin, ok := r.languages[name]; ok {
+		copied := *builtin
+		syntax = &copied
+	}
+	if lc.Line != nil {
+		syntax.Line = lc.Line
+	}
+	if lc.Block != nil {
+		syntax.Block = lc.Block
+	}
+	if lc.Strings != nil {
+		syntax.Strings = lc.Strings
+	}
+	if lc.Nested != nil {
+		syntax.Nested = *lc.Nested
+	}
+	if err := validate(syntax); err != nil {
+		return err
+	}
+	r.languages[name] = syntax
+
+	for _, ext := range lc.Extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		r.extensions[ext] = name
+	}
+	for _, file := range lc.Filenames {
+		r.filenames[file] = name
+	}
+	for _, interpreter := range lc.Interpreters {
+		r.interpreters[interpreter] = name
+	}
+	return nil
+}
+
+// validate returns an error if the syntax has no comments or has empty delimiters.
+func validate(syntax *Syntax) error {
+	if len(syntax.Line) == 0 && len(syntax.Block) == 0 {
+		return fmt.Errorf("no line or block comments")
+	}
+	for _, prefix := range syntax.Line {
+		if prefix == "" {
+			return fmt.Errorf("empty line comment prefix")
+		}
+	}
+	for _, block := range syntax.Block {
+		if block.Start == "" || block.End == "" {
+			return fmt.Errorf("empty block comment delimiters")
+		}
+	}
+	for _, str := range syntax.Strings {
+		if str.Start == "" || str.End == "" {
+			return fmt.Errorf("empty string delimiters")
+		}
+	}
+	return nil
+}
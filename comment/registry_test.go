@@ -0,0 +1,54 @@
+package comment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "languages.json")
+	config := `{"languages": {
+		"ocaml": {"block": [{"start": "(*", "end": "*)"}], "strings": [{"start": "\"", "end": "\"", "escape": true}], "nested": true, "extensions": ["ml", ".MLI"]},
+		"lua": {"extensions": [".p8"]},
+		"sql": {"line": ["--", "#"]}
+	}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ocaml := r.Detect("main.ml", nil)
+	if ocaml == nil || r.Detect("main.mli", nil) != ocaml {
+		t.Fatalf("expected ocaml syntax, got %+v", ocaml)
+	}
+	got := comments(ocaml, `(* a (* b *) c *) "(* no *)" (* TODO *)`)
+	if strings.Join(got, "\n") != " a (* b *) c | TODO " {
+		t.Errorf("unexpected ocaml comments: %q", got)
+	}
+	if lua := r.Detect("cart.p8", nil); lua == nil || lua.Name != "lua" {
+		t.Errorf("expected lua syntax for .p8, got %+v", lua)
+	}
+	sql := r.Detect("query.sql", nil)
+	if sql == nil || len(sql.Line) != 2 || len(sql.Block) != 1 {
+		t.Errorf("expected sql syntax with overridden line comments, got %+v", sql)
+	}
+	if len(languages["sql"].Line) != 1 || Detect("cart.p8", nil) != nil {
+		t.Error("built-in languages were modified")
+	}
+
+	invalid := []string{
+		`{"languages": {"x": {"extensions": [".x"]}}}`,
+		`{"languages": {"x": {"block": [{"start": "(*"}]}}}`,
+		`{"languages": {"x": {"line": ["#"], "typo": true}}}`,
+	}
+	for _, config := range invalid {
+		if err := NewRegistry().load([]byte(config)); err == nil {
+			t.Errorf("expected an error for %s", config)
+		}
+	}
+}
@@ -1,22 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"regexp"
+	"syscall"
+	"time"
 
 	"github.com/akamensky/argparse"
 	logging "github.com/op/go-logging"
 
+	"github.com/mathpn/listme/blame"
+	"github.com/mathpn/listme/comment"
 	"github.com/mathpn/listme/pretty"
 	"github.com/mathpn/listme/search"
 )
 
 var log = logging.MustGetLogger("listme")
 var format = logging.MustStringFormatter(`%{color}%{level}%{color:reset}: %{message}`)
-var tags = []string{"BUG", "FIXME", "XXX", "TODO", "HACK", "OPTIMIZE", "NOTE"}
 var tagValRegex = regexp.MustCompile(`^(\w+)$`)
 
+// Exit codes
+const (
+	exitOK          = 0
+	exitError       = 1 // invalid options or failure to write the output
+	exitUsage       = 2 // invalid command line arguments
+	exitFileErrors  = 3 // some files could not be scanned
+	exitInterrupted = 4 // interrupted or timed out, results are incomplete
+	exitExpired     = 5 // the due date of some comments has passed
+)
+
 func validateTags(tags []string) error {
 	for _, tag := range tags {
 		match := tagValRegex.MatchString(tag)
@@ -30,29 +46,43 @@ func validateTags(tags []string) error {
 func main() {
 	parser := argparse.NewParser("listme", "Summarize you FIXME, TODO, XXX (and other tags) comments so you don't forget them.")
 	path := parser.StringPositional(&argparse.Options{Help: "Path to folder or file to be searched. Search is recursive."})
-	tags := parser.StringList("T", "tags", &argparse.Options{Default: tags, Validate: validateTags, Help: "Tags to search for, input should be separated by spaces"})
+	tags := parser.StringList("T", "tags", &argparse.Options{Default: search.DefaultTags, Validate: validateTags, Help: "Tags to search for, input should be separated by spaces"})
 	glob := parser.String("g", "glob", &argparse.Options{Default: "*", Help: "Glob pattern to filter files in the search. Use a single-quoted string. Example: '*.go'"})
 	author := parser.String("a", "author", &argparse.Options{Help: "Filter lines by commit author"})
+	authorEmail := parser.String("e", "author-email", &argparse.Options{Help: "Filter lines by commit author email"})
+	assignee := parser.String("", "assignee", &argparse.Options{Help: "Filter tags by assignee, e.g. alice for TODO(alice)"})
+	issue := parser.String("", "issue", &argparse.Options{Help: "Filter tags by referenced issue, e.g. #1234 for FIXME[#1234]"})
+	dueBefore := parser.String("", "due-before", &argparse.Options{Help: "Filter tags with a due date before the specified date (YYYY-MM-DD), e.g. TODO(2025-03-01)"})
+	checkExpired := parser.Flag("", "check-expired", &argparse.Options{Help: "List only comments whose due date has passed or is near, e.g. TODO(until 2025-06-30), and exit with an error if any has passed"})
+	warnDays := parser.Int("", "warn-days", &argparse.Options{Default: 7, Help: "Number of days before the due date to start warning about comments in check mode"})
 	ageFilter := parser.Int("n", "newer-than", &argparse.Options{Default: -1, Help: "Filters lines based on the age of commits, showing only lines committed within the specified number of days"})
 	oldCommitLimit := parser.Int("o", "old-commit-mark-limit", &argparse.Options{Default: 60, Help: "Sets the age limit for marking commits as old, with commits older than the specified limit being marked"})
 	maxFileSize := parser.Int("f", "max-file-size", &argparse.Options{Default: 5, Help: "Maximum file size to scan (in MB)"})
 	fullPath := parser.Flag("F", "full-path", &argparse.Options{Help: "Print full absolute path of the files"})
 	noAuthor := parser.Flag("A", "no-author", &argparse.Options{Help: "Do not print git author information"})
+	showEmail := parser.Flag("", "show-email", &argparse.Options{Help: "Print the author email instead of the author name"})
+	noCache := parser.Flag("", "no-cache", &argparse.Options{Help: "Do not read or write the git blame cache"})
 	noSummary := parser.Flag("S", "no-summary", &argparse.Options{Help: "Do not print summary box for each file"})
 	bw := parser.Flag("b", "bw", &argparse.Options{Help: "Use black and white style"})
 	plain := parser.Flag("p", "plain", &argparse.Options{Help: "Use plain style. Ideal for machine consumption. Used by default when redirecting the output"})
+	outFormat := parser.Selector("", "format", search.Formats(), &argparse.Options{Default: search.TextFormat, Help: "Output format. The text format uses the style selected by the other style options"})
+	groupBy := parser.Selector("", "group-by", []string{search.GroupByFile, search.GroupByTag}, &argparse.Options{Default: search.GroupByFile, Help: "Group matches by file or by tag. Used by the markdown format"})
+	languages := parser.String("", "languages", &argparse.Options{Help: "JSON file with the comment syntax of additional languages. Default: $XDG_CONFIG_HOME/listme/languages.json if it exists"})
+	columns := parser.String("", "columns", &argparse.Options{Default: search.DefaultColumns, Help: "Comma-separated columns for the csv and tsv formats. Available: path, line, end_line, column, tag, text, assignee, issue, priority, due, author, email, date, time, committer, commit, summary"})
+	timeout := parser.Int("", "timeout", &argparse.Options{Default: 0, Help: "Stop the search after the specified number of seconds and print the results found so far. 0 disables the timeout"})
 	workers := parser.Int("w", "workers", &argparse.Options{Default: 128, Help: "[debug] Number of search workers. There's likely no need to change this"})
 	verbose := parser.Flag("v", "verbose", &argparse.Options{Help: "Enable info logging level"})
 	debug := parser.Flag("d", "debug", &argparse.Options{Help: "Add debug verbosity"})
 
 	err := parser.Parse(os.Args)
 	if err != nil {
-		fmt.Print(parser.Usage(err))
-		panic(err)
+		fmt.Fprint(os.Stderr, parser.Usage(err))
+		os.Exit(exitUsage)
 	}
 
 	if *maxFileSize <= 0 {
-		panic("max-file-size must be a positive integer")
+		fmt.Fprint(os.Stderr, parser.Usage("max-file-size must be a positive integer"))
+		os.Exit(exitUsage)
 	}
 
 	logging.SetFormatter(format)
@@ -67,27 +97,149 @@ func main() {
 		logging.SetLevel(logging.DEBUG, "")
 	}
 
+	if *outFormat != search.TextFormat && (*bw || *plain) {
+		log.Fatalf("style options can't be combined with the %s format", *outFormat)
+	}
+
 	style, err := pretty.GetStyle(*bw, *plain)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	params, err := search.NewSearchParams(
-		*path,
-		*tags,
-		*workers,
-		style,
-		*oldCommitLimit,
-		*ageFilter,
-		int64(*maxFileSize),
-		*fullPath,
-		*noSummary,
-		*noAuthor,
-		*glob,
-		*author,
-	)
+	columnList, err := search.ParseColumns(*columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var due time.Time
+	if *dueBefore != "" {
+		if due, err = search.ParseDueDate(*dueBefore); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	languageRegistry, err := loadLanguages(*languages)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	currentTime := time.Now()
+	var since time.Time
+	if *ageFilter != -1 {
+		since = currentTime.AddDate(0, 0, -*ageFilter)
+	}
+
+	opts := search.Options{
+		Path:         *path,
+		Tags:         *tags,
+		Glob:         *glob,
+		Author:       *author,
+		AuthorEmail:  *authorEmail,
+		Assignee:     *assignee,
+		Issue:        *issue,
+		DueBefore:    due,
+		CheckExpired: *checkExpired,
+		WarnDays:     *warnDays,
+		Since:        since,
+		MaxFileSize:  int64(*maxFileSize),
+		Workers:      *workers,
+		Languages:    languageRegistry,
+		Blame:        !*noAuthor,
+	}
+	if !*noCache {
+		opts.Blamer = cachedBlamer()
+	}
+	renderOpts := search.RenderOptions{
+		Style:         style,
+		OldCommitTime: currentTime.AddDate(0, 0, -*oldCommitLimit),
+		FullPath:      *fullPath,
+		NoSummary:     *noSummary,
+		NoAuthor:      *noAuthor,
+		ShowEmail:     *showEmail,
+		GroupByTag:    *groupBy == search.GroupByTag,
+		Columns:       columnList,
+	}
+	params, err := search.NewSearchParams(*outFormat, opts, renderOpts)
 	if err != nil {
 		log.Fatal(err)
 	}
-	search.Search(params)
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore the default behavior so that a second interrupt exits immediately
+		<-sigCtx.Done()
+		stop()
+	}()
+
+	ctx := sigCtx
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(*timeout)*time.Second)
+		defer cancel()
+	}
+
+	err = search.Search(ctx, params)
+	code := exitCode(err)
+	switch code {
+	case exitOK:
+	case exitInterrupted:
+		log.Warningf("search stopped early, results are incomplete: %s", err)
+	default:
+		log.Error(err)
+	}
+	os.Exit(code)
+}
+
+// loadLanguages returns the comment syntax registry of the languages file. If path is
+// empty, the default languages file is used when it exists, otherwise the built-in languages.
+func loadLanguages(path string) (*comment.Registry, error) {
+	if path == "" {
+		defaultPath, err := comment.DefaultConfigPath()
+		if err != nil {
+			return nil, nil
+		}
+		if _, err := os.Stat(defaultPath); err != nil {
+			return nil, nil
+		}
+		path = defaultPath
+	}
+	log.Infof("loading comment syntax of languages from %s", path)
+	languages, err := comment.LoadRegistry(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load languages: %w", err)
+	}
+	return languages, nil
+}
+
+// cachedBlamer returns a git blamer backed by the default cache, after pruning
+// its old entries, or nil if the cache is not available.
+func cachedBlamer() blame.Blamer {
+	dir, err := blame.DefaultCacheDir()
+	if err != nil {
+		log.Warningf("git blame cache disabled: %s", err)
+		return nil
+	}
+	cached := blame.NewCachedBlamer(blame.NewGitBlamer(), dir)
+	if err := cached.Prune(blame.DefaultCacheMaxAge); err != nil {
+		log.Warningf("failed to prune the git blame cache: %s", err)
+	}
+	return cached
+}
+
+func exitCode(err error) int {
+	var fileErrs search.FileErrors
+	var expired search.ExpiredComments
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return exitInterrupted
+	case errors.As(err, &expired):
+		return exitExpired
+	case errors.As(err, &fileErrs):
+		return exitFileErrors
+	default:
+		return exitError
+	}
 }
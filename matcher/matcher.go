@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -36,42 +37,114 @@ type Matcher interface {
 }
 
 type matcher struct {
+	fsys *fileSystem
 	root string
 	gi   map[string]*gitignore.GitIgnore
 	glob string
 }
 
+// fileSystem holds the path handling and file access functions used by the matcher,
+// either for the OS filesystem or for an fs.FS.
+type fileSystem struct {
+	walkDir func(root string, fn fs.WalkDirFunc) error
+	exists  func(name string) bool
+	compile func(name string) (*gitignore.GitIgnore, error)
+	join    func(elem ...string) string
+	dir     func(path string) string
+	base    func(path string) string
+	rel     func(basepath, targpath string) (string, error)
+}
+
+var osFileSystem = &fileSystem{
+	walkDir: filepath.WalkDir,
+	exists: func(name string) bool {
+		_, err := os.Stat(name)
+		return err == nil
+	},
+	compile: gitignore.CompileIgnoreFile,
+	join:    filepath.Join,
+	dir:     filepath.Dir,
+	base:    filepath.Base,
+	rel:     filepath.Rel,
+}
+
+func newFSFileSystem(fsys fs.FS) *fileSystem {
+	return &fileSystem{
+		walkDir: func(root string, fn fs.WalkDirFunc) error {
+			return fs.WalkDir(fsys, root, fn)
+		},
+		exists: func(name string) bool {
+			_, err := fs.Stat(fsys, name)
+			return err == nil
+		},
+		compile: func(name string) (*gitignore.GitIgnore, error) {
+			content, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			return gitignore.CompileIgnoreLines(strings.Split(string(content), "\n")...), nil
+		},
+		join: path.Join,
+		dir:  path.Dir,
+		base: path.Base,
+		rel:  slashRel,
+	}
+}
+
+// slashRel is filepath.Rel for the slash-separated, unrooted paths of fs.FS.
+// Paths outside of basepath are reported as "..".
+func slashRel(basepath, targpath string) (string, error) {
+	switch {
+	case basepath == targpath:
+		return ".", nil
+	case basepath == ".":
+		return targpath, nil
+	case strings.HasPrefix(targpath, basepath+"/"):
+		return targpath[len(basepath)+1:], nil
+	default:
+		return "..", nil
+	}
+}
+
 // NewMatcher returns a Matcher. If a git repository is found on the provided path or on a
 // parent directory, all .gitignore files are respected. The provided glob provides an additional
 // filter.
 //
 // If a glob pattern is not needed, pass '*'.
 func NewMatcher(path string, glob string) Matcher {
-	path = filepath.Clean(path)
-	repoRoot, err := detectDotGit(path)
+	return newMatcher(osFileSystem, filepath.Clean(path), glob)
+}
+
+// NewMatcherFS returns a Matcher for paths of fsys, such as an embed.FS or a zip archive.
+// It works like NewMatcher, but the git repository is only searched for up to the root of fsys.
+func NewMatcherFS(fsys fs.FS, name string, glob string) Matcher {
+	return newMatcher(newFSFileSystem(fsys), path.Clean(name), glob)
+}
+
+func newMatcher(fsys *fileSystem, path string, glob string) Matcher {
+	repoRoot, err := detectDotGit(fsys, path)
 	if err != nil {
 		log.Debugf("no git repository found in %s: %s", path, err)
-		return &matcher{root: path, gi: make(map[string]*gitignore.GitIgnore, 0), glob: glob}
+		return &matcher{fsys: fsys, root: path, gi: make(map[string]*gitignore.GitIgnore, 0), glob: glob}
 	}
-	matchers, err := walkGitignore(repoRoot, path)
+	matchers, err := walkGitignore(fsys, repoRoot, path)
 	if err != nil {
 		log.Errorf("error while parsing .gitignore files: %s", err)
 	}
-	return &matcher{root: repoRoot, gi: matchers, glob: glob}
-
+	return &matcher{fsys: fsys, root: repoRoot, gi: matchers, glob: glob}
 }
 
-func walkGitignore(repoRoot string, refPath string) (map[string]*gitignore.GitIgnore, error) {
+func walkGitignore(fsys *fileSystem, repoRoot string, refPath string) (map[string]*gitignore.GitIgnore, error) {
 	matchers := make(map[string]*gitignore.GitIgnore)
 
 	parseGitignore := func(path string) {
-		matcher, err := gitignore.CompileIgnoreFile(path)
+		matcher, err := fsys.compile(path)
 		if err != nil {
 			log.Warningf("failed to parse .gitignore %s: %v\n", path, err)
 			return
 		}
 
-		dir := filepath.Dir(path)
+		dir := fsys.dir(path)
 		matchers[dir] = matcher
 	}
 
@@ -93,15 +166,15 @@ func walkGitignore(repoRoot string, refPath string) (map[string]*gitignore.GitIg
 		}
 
 		// Check if the path is in the hierarchy of refPath
-		isSub, _ := isSubfolder(path, refPath)
-		isSubRev, _ := isSubfolder(refPath, path)
+		isSub, _ := isSubfolder(fsys, path, refPath)
+		isSubRev, _ := isSubfolder(fsys, refPath, path)
 		if !isSub && !isSubRev {
 			log.Debugf(".gitignore search: skipping %s since it's outside of %s hierachy", path, refPath)
 			return filepath.SkipDir
 		}
 
 		// If an entire folder is ignored by a .gitignore, stop walking
-		if gitignoreMatch(matchers, path, repoRoot) {
+		if gitignoreMatch(fsys, matchers, path, repoRoot) {
 			log.Debugf(".gitignore search: skipping %s due to .gitignore patterns", path)
 			return filepath.SkipDir
 		}
@@ -109,8 +182,8 @@ func walkGitignore(repoRoot string, refPath string) (map[string]*gitignore.GitIg
 		// Check if it's a directory and not a .git directory
 		if !strings.HasSuffix(path, gitDirName) {
 			// Check if a .gitignore file exists in the directory
-			gitignorePath := filepath.Join(path, ".gitignore")
-			if _, err := os.Stat(gitignorePath); err == nil {
+			gitignorePath := fsys.join(path, ".gitignore")
+			if fsys.exists(gitignorePath) {
 				log.Debugf("parsing new .gitignore file: %s", gitignorePath)
 				parseGitignore(gitignorePath)
 			}
@@ -119,7 +192,7 @@ func walkGitignore(repoRoot string, refPath string) (map[string]*gitignore.GitIg
 		return nil
 	}
 
-	err := filepath.WalkDir(repoRoot, walker)
+	err := fsys.walkDir(repoRoot, walker)
 	if err != nil {
 		err = fmt.Errorf("error walking directory: %s", err)
 		return matchers, err
@@ -128,10 +201,10 @@ func walkGitignore(repoRoot string, refPath string) (map[string]*gitignore.GitIg
 }
 
 func (m *matcher) Match(path string) MatchType {
-	if gitignoreMatch(m.gi, path, m.root) {
+	if gitignoreMatch(m.fsys, m.gi, path, m.root) {
 		return GitIgnore
 	}
-	base := filepath.Base(path)
+	base := m.fsys.base(path)
 	matched, err := filepath.Match(m.glob, base)
 	if err != nil {
 		log.Infof("glob match error with path %s: %s", path, err)
@@ -143,16 +216,16 @@ func (m *matcher) Match(path string) MatchType {
 	return Match
 }
 
-func gitignoreMatch(matchers map[string]*gitignore.GitIgnore, path string, root string) bool {
+func gitignoreMatch(fsys *fileSystem, matchers map[string]*gitignore.GitIgnore, path string, root string) bool {
 	if len(matchers) == 0 {
 		return false
 	}
 
-	dir := filepath.Dir(path)
+	dir := fsys.dir(path)
 	for {
 		matcher, ok := matchers[dir]
 		if ok {
-			checkPath, err := filepath.Rel(dir, path)
+			checkPath, err := fsys.rel(dir, path)
 			if err == nil {
 				if matcher.MatchesPath(checkPath) {
 					return true
@@ -168,7 +241,7 @@ func gitignoreMatch(matchers map[string]*gitignore.GitIgnore, path string, root
 		}
 
 		// Move up one directory in the hierarchy
-		parentDir := filepath.Dir(dir)
+		parentDir := fsys.dir(dir)
 		if parentDir == dir {
 			return false
 		}
@@ -177,11 +250,16 @@ func gitignoreMatch(matchers map[string]*gitignore.GitIgnore, path string, root
 }
 
 // MatchGit returns true if the path is a .git folder or is inside a .git folder.
+// Both OS paths and the slash-separated paths of fs.FS are supported.
 func MatchGit(path string) bool {
-	return strings.HasSuffix(path, separator+gitDirName) || strings.Contains(path, separator+gitDirName+separator)
+	if strings.HasSuffix(path, separator+gitDirName) || strings.Contains(path, separator+gitDirName+separator) {
+		return true
+	}
+	return path == gitDirName || strings.HasPrefix(path, gitDirName+"/") ||
+		strings.HasSuffix(path, "/"+gitDirName) || strings.Contains(path, "/"+gitDirName+"/")
 }
 
-func detectDotGit(startDir string) (string, error) {
+func detectDotGit(fsys *fileSystem, startDir string) (string, error) {
 	startDir, err := replaceTildeWithHomeDir(startDir)
 	if err != nil {
 		return "", err
@@ -193,12 +271,12 @@ func detectDotGit(startDir string) (string, error) {
 			return "", fmt.Errorf("reached the system root directory")
 		}
 
-		if hasGitDirectory(startDir) {
+		if hasGitDirectory(fsys, startDir) {
 			log.Debugf("found git repo root in %s", startDir)
 			return startDir, nil
 		}
 
-		parentDir := filepath.Dir(startDir)
+		parentDir := fsys.dir(startDir)
 
 		if parentDir == startDir {
 			return "", fmt.Errorf("reached the system root directory without finding a .git directory")
@@ -208,8 +286,8 @@ func detectDotGit(startDir string) (string, error) {
 	}
 }
 
-func isSubfolder(subfolder, parentFolder string) (bool, error) {
-	relPath, err := filepath.Rel(parentFolder, subfolder)
+func isSubfolder(fsys *fileSystem, subfolder, parentFolder string) (bool, error) {
+	relPath, err := fsys.rel(parentFolder, subfolder)
 	if err != nil {
 		log.Errorf("subfolder check failed: %s", err)
 		return false, err
@@ -225,10 +303,8 @@ func isSystemRoot(dir string) bool {
 }
 
 // Check if a directory contains a .git directory
-func hasGitDirectory(dir string) bool {
-	gitDir := filepath.Join(dir, gitDirName)
-	_, err := os.Stat(gitDir)
-	return err == nil
+func hasGitDirectory(fsys *fileSystem, dir string) bool {
+	return fsys.exists(fsys.join(dir, gitDirName))
 }
 
 func replaceTildeWithHomeDir(path string) (string, error) {
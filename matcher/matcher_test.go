@@ -12,7 +12,7 @@ func BenchmarkGit(b *testing.B) {
 			if err != nil {
 				panic(err)
 			}
-			_, err = detectDotGit(fpath)
+			_, err = detectDotGit(osFileSystem, fpath)
 			if err != nil {
 				panic(err)
 			}
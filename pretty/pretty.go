@@ -20,6 +20,12 @@ const (
 	BWStyle
 	PlainStyle
 )
+
+// IsTerminal returns true if the style is meant to be read in a terminal.
+func (s Style) IsTerminal() bool {
+	return s == FullStyle || s == BWStyle
+}
+
 const boldCode = "\x1b[1m"
 const resetBold = "\x1b[22m"
 
@@ -135,15 +141,16 @@ func Colorize(text string, tag string, style Style) string {
 //
 //	[OLD John Doe]
 //
-// Color is added according to the style.
-func PrettyBlame(blame *blame.LineBlame, oldCommitTime time.Time, style Style) string {
-	blameStr := fmt.Sprintf("[%s]", blame.Author)
-	if blame.Time.IsZero() {
+// The author is truncated to blame.MaxAuthorLength and color is added according to the style.
+func PrettyBlame(lineBlame *blame.LineBlame, oldCommitTime time.Time, style Style) string {
+	author := blame.TruncateName(lineBlame.Author, blame.MaxAuthorLength)
+	blameStr := fmt.Sprintf("[%s]", author)
+	if lineBlame.Time.IsZero() {
 		return blameStr
 	}
 
-	if blame.Time.Before(oldCommitTime) {
-		blameStr = fmt.Sprintf("[OLD %s]", blame.Author)
+	if lineBlame.Time.Before(oldCommitTime) {
+		blameStr = fmt.Sprintf("[OLD %s]", author)
 		if style == FullStyle {
 			blameStr = oldCommitStyle.Render(blameStr)
 		}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var githubDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+type gitlabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitlabLocation `json:"location"`
+}
+
+type gitlabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitlabLines `json:"lines"`
+}
+
+type gitlabLines struct {
+	Begin int `json:"begin"`
+	End   int `json:"end,omitempty"`
+}
+
+// matchKey identifies a comment by its path, tag and text. It doesn't depend on the
+// line number, so it's stable while the comment moves around in the file.
+// occurrence distinguishes identical comments in the same file.
+func matchKey(path string, l *Match, occurrence int) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%d", filepath.ToSlash(path), l.Tag, strings.TrimSpace(l.Text), occurrence)
+}
+
+// matchDigest returns a SHA-256 digest of the matchKey.
+func matchDigest(path string, l *Match, occurrence int) [sha256.Size]byte {
+	return sha256.Sum256([]byte(matchKey(path, l, occurrence)))
+}
+
+// occurrences returns, for each line of the result, how many identical comments
+// precede it in the same file.
+func (r *Result) occurrences() []int {
+	seen := make(map[string]int, len(r.Matches))
+	occurrences := make([]int, len(r.Matches))
+	for i, line := range r.Matches {
+		key := line.Tag + "\x00" + strings.TrimSpace(line.Text)
+		occurrences[i] = seen[key]
+		seen[key]++
+	}
+	return occurrences
+}
+
+// Render the line and write it to w as a GitHub Actions workflow command,
+// which annotates the line in the workflow summary and in pull requests.
+// Every tag is reported as a warning.
+func (l *Match) GitHubRender(w io.Writer, path string) error {
+	_, err := fmt.Fprintf(
+		w,
+		"::warning file=%s,line=%d,col=%d,title=%s::%s\n",
+		githubPropertyEscaper.Replace(filepath.ToSlash(path)),
+		l.Line,
+		l.Column,
+		githubPropertyEscaper.Replace(l.Tag),
+		githubDataEscaper.Replace(lineMessage(l)),
+	)
+	return err
+}
+
+func gitlabSeverity(tag string) string {
+	switch tagSeverity(tag) {
+	case severityError:
+		return "critical"
+	case severityWarning:
+		return "major"
+	default:
+		return "info"
+	}
+}
+
+// writeGitLab writes a GitLab Code Quality report to w: a JSON array with one issue per
+// matching line. Fingerprints are stable across runs as long as the comment doesn't change.
+func writeGitLab(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	issues := make([]*gitlabIssue, 0)
+	for _, r := range results {
+		path := filepath.ToSlash(r.displayPath(opts.FullPath))
+		occurrences := r.occurrences()
+		for i, line := range r.Matches {
+			digest := matchDigest(path, line, occurrences[i])
+			issues = append(issues, &gitlabIssue{
+				Description: lineMessage(line),
+				CheckName:   line.Tag,
+				Fingerprint: hex.EncodeToString(digest[:]),
+				Severity:    gitlabSeverity(line.Tag),
+				Location:    gitlabLocation{Path: path, Lines: gitlabLines{Begin: line.Line, End: line.lastLine()}},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(issues)
+}
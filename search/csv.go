@@ -0,0 +1,138 @@
+package search
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultColumns are the columns written by the delimited formats when none are specified.
+const DefaultColumns = "path,line,tag,text,author,date"
+
+// csvColumns maps each column name accepted by --columns to its value for a matching line.
+var csvColumns = map[string]func(path string, l *Match) string{
+	"path":     func(path string, l *Match) string { return path },
+	"line":     func(path string, l *Match) string { return strconv.Itoa(l.Line) },
+	"end_line": func(path string, l *Match) string { return strconv.Itoa(l.lastLine()) },
+	"column":   func(path string, l *Match) string { return strconv.Itoa(l.Column) },
+	"tag":      func(path string, l *Match) string { return l.Tag },
+	"text":     func(path string, l *Match) string { return strings.TrimSpace(l.Text) },
+	"assignee": func(path string, l *Match) string { return l.Assignee },
+	"issue":    func(path string, l *Match) string { return l.Issue },
+	"priority": func(path string, l *Match) string { return l.Priority },
+	"due":      func(path string, l *Match) string { return l.dueDate() },
+	"author": func(path string, l *Match) string {
+		if l.Blame == nil {
+			return ""
+		}
+		return l.Blame.Author
+	},
+	"email": func(path string, l *Match) string {
+		if l.Blame == nil {
+			return ""
+		}
+		return l.Blame.Email
+	},
+	"date": func(path string, l *Match) string {
+		if l.Blame == nil || l.Blame.Time.IsZero() {
+			return ""
+		}
+		return l.Blame.Time.Format("2006-01-02")
+	},
+	"time": func(path string, l *Match) string {
+		if l.Blame == nil || l.Blame.Time.IsZero() {
+			return ""
+		}
+		return l.Blame.Time.UTC().Format(time.RFC3339)
+	},
+	"committer": func(path string, l *Match) string {
+		if l.Blame == nil {
+			return ""
+		}
+		return l.Blame.Committer
+	},
+	"commit": func(path string, l *Match) string {
+		if l.Blame == nil {
+			return ""
+		}
+		return l.Blame.Commit
+	},
+	"summary": func(path string, l *Match) string {
+		if l.Blame == nil {
+			return ""
+		}
+		return l.Blame.Summary
+	},
+}
+
+// blameColumns are the columns filled with Git blame information.
+var blameColumns = map[string]bool{
+	"author": true, "email": true, "date": true, "time": true, "committer": true, "commit": true, "summary": true,
+}
+
+// columnsUseBlame returns true if any of the columns is filled with Git blame information.
+func columnsUseBlame(opts *RenderOptions) bool {
+	for _, column := range opts.Columns {
+		if blameColumns[column] {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseColumns splits a comma-separated list of column names, e.g. DefaultColumns,
+// and validates each one.
+func ParseColumns(columns string) ([]string, error) {
+	var parsed []string
+	for _, column := range strings.Split(columns, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if column == "" {
+			continue
+		}
+		if _, ok := csvColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown column %s", column)
+		}
+		parsed = append(parsed, column)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("at least one column must be specified")
+	}
+	return parsed, nil
+}
+
+func writeDelimited(w io.Writer, comma rune, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	if err := cw.Write(opts.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(opts.Columns))
+	for _, r := range results {
+		path := r.displayPath(opts.FullPath)
+		for _, line := range r.Matches {
+			for i, column := range opts.Columns {
+				record[i] = csvColumns[column](path, line)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeCSV writes a header and one comma-separated record per matching line to w.
+func writeCSV(w io.Writer, results []*Result, opts *RenderOptions) error {
+	return writeDelimited(w, ',', results, opts)
+}
+
+// writeTSV writes a header and one tab-separated record per matching line to w.
+func writeTSV(w io.Writer, results []*Result, opts *RenderOptions) error {
+	return writeDelimited(w, '\t', results, opts)
+}
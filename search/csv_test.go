@@ -0,0 +1,42 @@
+package search
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCSVQuoting(t *testing.T) {
+	opts := &RenderOptions{FullPath: true, Columns: []string{"path", "line", "tag", "text"}}
+	results := []*Result{{
+		Path: "a,b.go",
+		Matches: []*Match{
+			{Line: 3, Tag: "TODO", Text: ` say "hi", then leave`},
+			{Line: 10, Tag: "FIXME", Text: "plain"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, results, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := "path,line,tag,text\n" +
+		"\"a,b.go\",3,TODO,\"say \"\"hi\"\", then leave\"\n" +
+		"\"a,b.go\",10,FIXME,plain\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected CSV output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns(" Path, line,,text ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 3 || columns[0] != "path" || columns[1] != "line" || columns[2] != "text" {
+		t.Errorf("unexpected columns: %v", columns)
+	}
+
+	if _, err := ParseColumns("path,unknown"); err == nil {
+		t.Error("expected error for unknown column")
+	}
+}
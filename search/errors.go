@@ -0,0 +1,63 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrNarrowTerminal is returned when the terminal is too narrow to render the text format.
+var ErrNarrowTerminal = errors.New("terminal is too narrow")
+
+// FileError records a file or folder that couldn't be scanned.
+// The search continues with the remaining files.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FileErrors is returned by Search and Run when some files couldn't be scanned.
+// The results of all other files are still available.
+type FileErrors []*FileError
+
+func (e FileErrors) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d file(s) could not be scanned:", len(e))
+	for _, err := range e {
+		fmt.Fprintf(&b, "\n  - %s", err)
+	}
+	return b.String()
+}
+
+// errorCollector gathers file errors from concurrent search workers.
+type errorCollector struct {
+	mu   sync.Mutex
+	errs FileErrors
+}
+
+func (c *errorCollector) add(path string, err error) {
+	// reported once by the caller through FileErrors
+	log.Debugf("%s: %s", path, err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, &FileError{Path: path, Err: err})
+}
+
+// err returns the collected errors, or nil if there are none.
+func (c *errorCollector) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
@@ -0,0 +1,96 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExpiredComment records a comment whose due date has passed or is not a valid date.
+type ExpiredComment struct {
+	Path  string
+	Match *Match
+}
+
+func (e *ExpiredComment) Error() string {
+	if e.Match.invalidDue != "" {
+		return fmt.Sprintf("%s:%d: %s (invalid due date %s)", e.Path, e.Match.Line, lineMessage(e.Match), e.Match.invalidDue)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Match.Line, lineMessage(e.Match))
+}
+
+// ExpiredComments is returned by Search and Run in check mode when the due date of
+// some comments has passed or is invalid. The results of the search are still available.
+type ExpiredComments []*ExpiredComment
+
+func (e ExpiredComments) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d comment(s) expired:", len(e))
+	for _, err := range e {
+		fmt.Fprintf(&b, "\n  - %s", err)
+	}
+	return b.String()
+}
+
+// today returns the local date of t as midnight UTC, comparable with parsed due dates.
+func today(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// expiryCollector gathers expired comments from concurrent search workers.
+// Comments are valid until the end of their due date. A nil collector ignores everything.
+type expiryCollector struct {
+	today   time.Time
+	mu      sync.Mutex
+	expired ExpiredComments
+}
+
+func newExpiryCollector(params *searchParams) *expiryCollector {
+	if !params.checkExpired {
+		return nil
+	}
+	return &expiryCollector{today: today(time.Now())}
+}
+
+// add records the expired comments of a result and warns about the ones that are due soon.
+func (c *expiryCollector) add(r *Result, fullPath bool) {
+	if c == nil {
+		return
+	}
+	path := r.displayPath(fullPath)
+	for _, line := range r.Matches {
+		if line.Due.IsZero() && line.invalidDue == "" {
+			continue
+		}
+		if line.invalidDue == "" && !line.Due.Before(c.today) {
+			days := int(line.Due.Sub(c.today).Hours() / 24)
+			log.Warningf("%s:%d: %s expires in %d day(s)", path, line.Line, line.Label(), days)
+			continue
+		}
+		c.mu.Lock()
+		c.expired = append(c.expired, &ExpiredComment{Path: path, Match: line})
+		c.mu.Unlock()
+	}
+}
+
+// err returns the expired comments sorted by path and line, or nil if there are none.
+func (c *expiryCollector) err() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.expired) == 0 {
+		return nil
+	}
+	sort.Slice(c.expired, func(i, j int) bool {
+		a, b := c.expired[i], c.expired[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Match.Line < b.Match.Line
+	})
+	return c.expired
+}
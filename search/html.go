@@ -0,0 +1,181 @@
+package search
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mathpn/listme/pretty"
+)
+
+type htmlTagCount struct {
+	Tag   string
+	Label string
+	Count int
+}
+
+type htmlFile struct {
+	Path  string
+	Count int
+	Tags  []htmlTagCount
+}
+
+type htmlMatch struct {
+	Path    string
+	Line    int
+	Tag     string
+	Label   string
+	Text    string
+	Author  string
+	Commit  string
+	Summary string
+	Old     bool
+	Date    string
+	Unix    int64
+	AgeDays int
+}
+
+type htmlReport struct {
+	Generated string
+	Total     int
+	Tags      []htmlTagCount
+	Files     []htmlFile
+	Matches   []htmlMatch
+}
+
+// sortedTagCounts converts a tag counter to a slice sorted by tag.
+func sortedTagCounts(counter map[string]int) []htmlTagCount {
+	tags := make([]string, 0, len(counter))
+	for tag := range counter {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	counts := make([]htmlTagCount, 0, len(tags))
+	for _, tag := range tags {
+		counts = append(counts, htmlTagCount{Tag: tag, Label: pretty.Emojify(tag), Count: counter[tag]})
+	}
+	return counts
+}
+
+func newHTMLMatch(path string, l *Match, opts *RenderOptions, now time.Time) htmlMatch {
+	m := htmlMatch{Path: path, Line: l.Line, Tag: l.Tag, Label: pretty.Emojify(l.Tag) + l.metadataLabel(), Text: strings.TrimSpace(l.Text)}
+	if l.Blame == nil {
+		return m
+	}
+	m.Author = opts.displayBlame(l.Blame).Author
+	m.Commit = l.Blame.Commit
+	m.Summary = l.Blame.Summary
+	if !l.Blame.Time.IsZero() {
+		m.Old = l.Blame.Time.Before(opts.OldCommitTime)
+		m.Date = l.Blame.Time.Format("2006-01-02")
+		m.Unix = l.Blame.Time.Unix()
+		m.AgeDays = int(now.Sub(l.Blame.Time).Hours() / 24)
+	}
+	return m
+}
+
+// writeHTML writes a self-contained HTML page with tag summaries and a sortable table of matches.
+func writeHTML(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	now := time.Now()
+	report := htmlReport{Generated: now.Format(time.RFC1123)}
+
+	total := make(map[string]int)
+	for _, r := range results {
+		path := r.displayPath(opts.FullPath)
+		counter := r.tagCounts()
+		for tag, count := range counter {
+			total[tag] += count
+		}
+		report.Files = append(report.Files, htmlFile{Path: path, Count: len(r.Matches), Tags: sortedTagCounts(counter)})
+		for _, line := range r.Matches {
+			report.Matches = append(report.Matches, newHTMLMatch(path, line, opts, now))
+		}
+		report.Total += len(r.Matches)
+	}
+	report.Tags = sortedTagCounts(total)
+
+	return htmlTemplate.Execute(w, report)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>listme report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #d0d7de; vertical-align: top; }
+th.sortable { cursor: pointer; user-select: none; }
+th.sortable::after { content: " \2195"; color: #8c959f; }
+td.line, td.count { text-align: right; font-variant-numeric: tabular-nums; }
+code { font-size: 0.9em; }
+.muted { color: #57606a; }
+.tag { display: inline-block; padding: 0 0.4em; border-radius: 4px; white-space: nowrap; margin-right: 0.3em; }
+.tag-TODO { color: #5fafaf; }
+.tag-XXX { color: #000000; background: #d7af00; }
+.tag-FIXME { color: #ff0000; }
+.tag-OPTIMIZE { color: #d75f00; }
+.tag-BUG { color: #eeeeee; background: #870000; }
+.tag-NOTE { color: #87af87; }
+.tag-HACK { color: #a8a800; }
+.badge-old { display: inline-block; padding: 0 0.4em; border-radius: 4px; font-weight: bold; color: #dadada; background: #d70000; }
+</style>
+</head>
+<body>
+<h1>listme report</h1>
+<p class="muted">{{.Total}} comment(s) in {{len .Files}} file(s). Generated {{.Generated}}.</p>
+
+<h2>Summary</h2>
+<p>{{range .Tags}}<span class="tag tag-{{.Tag}}">{{.Label}} {{.Count}}</span>{{end}}</p>
+
+<h2>Files</h2>
+<table class="sortable-table">
+<thead><tr><th class="sortable" data-type="text">File</th><th class="sortable" data-type="number">Comments</th><th>Tags</th></tr></thead>
+<tbody>
+{{- range .Files}}
+<tr><td data-sort="{{.Path}}"><code>{{.Path}}</code></td><td class="count" data-sort="{{.Count}}">{{.Count}}</td><td>{{range .Tags}}<span class="tag tag-{{.Tag}}">{{.Label}} {{.Count}}</span>{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+
+<h2>Comments</h2>
+<table class="sortable-table">
+<thead><tr><th class="sortable" data-type="text">File</th><th class="sortable" data-type="number">Line</th><th class="sortable" data-type="text">Tag</th><th>Comment</th><th class="sortable" data-type="text">Author</th><th class="sortable" data-type="number">Age</th></tr></thead>
+<tbody>
+{{- range .Matches}}
+<tr><td data-sort="{{.Path}}"><code>{{.Path}}</code></td><td class="line" data-sort="{{.Line}}">{{.Line}}</td><td data-sort="{{.Tag}}"><span class="tag tag-{{.Tag}}">{{.Label}}</span></td><td>{{.Text}}</td><td data-sort="{{.Author}}"{{if .Commit}} title="{{printf "%.12s" .Commit}} {{.Summary}}"{{end}}>{{if .Old}}<span class="badge-old">OLD</span> {{end}}{{.Author}}</td><td data-sort="{{if .Unix}}{{.AgeDays}}{{else}}-1{{end}}">{{if .Unix}}<span title="{{.Date}}">{{.AgeDays}} day(s)</span>{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+
+<script>
+document.querySelectorAll("table.sortable-table").forEach(function (table) {
+  table.querySelectorAll("th.sortable").forEach(function (th) {
+    var ascending = true;
+    th.addEventListener("click", function () {
+      var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+      var numeric = th.dataset.type === "number";
+      var tbody = table.tBodies[0];
+      var rows = Array.prototype.slice.call(tbody.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[index].dataset.sort, y = b.cells[index].dataset.sort;
+        var cmp = numeric ? Number(x) - Number(y) : x.localeCompare(y);
+        return ascending ? cmp : -cmp;
+      });
+      ascending = !ascending;
+      rows.forEach(function (row) { tbody.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type jsonMatch struct {
+	Path           string     `json:"path"`
+	Line           int        `json:"line"`
+	EndLine        int        `json:"end_line"`
+	Column         int        `json:"column"`
+	Tag            string     `json:"tag"`
+	Text           string     `json:"text"`
+	Assignee       string     `json:"assignee,omitempty"`
+	Issue          string     `json:"issue,omitempty"`
+	Priority       string     `json:"priority,omitempty"`
+	Due            string     `json:"due,omitempty"`
+	Author         string     `json:"author,omitempty"`
+	Email          string     `json:"email,omitempty"`
+	Time           *time.Time `json:"time,omitempty"`
+	Committer      string     `json:"committer,omitempty"`
+	CommitterEmail string     `json:"committer_email,omitempty"`
+	CommitterTime  *time.Time `json:"committer_time,omitempty"`
+	Commit         string     `json:"commit,omitempty"`
+	Summary        string     `json:"summary,omitempty"`
+}
+
+type jsonReport struct {
+	Matches []*jsonMatch `json:"matches"`
+}
+
+func newJSONMatch(path string, l *Match) *jsonMatch {
+	m := &jsonMatch{
+		Path:     path,
+		Line:     l.Line,
+		EndLine:  l.lastLine(),
+		Column:   l.Column,
+		Tag:      l.Tag,
+		Text:     strings.TrimSpace(l.Text),
+		Assignee: l.Assignee,
+		Issue:    l.Issue,
+		Priority: l.Priority,
+		Due:      l.dueDate(),
+	}
+	if l.Blame != nil {
+		m.Author = l.Blame.Author
+		m.Email = l.Blame.Email
+		m.Time = utcTime(l.Blame.Time)
+		m.Committer = l.Blame.Committer
+		m.CommitterEmail = l.Blame.CommitterEmail
+		m.CommitterTime = utcTime(l.Blame.CommitterTime)
+		m.Commit = l.Blame.Commit
+		m.Summary = l.Blame.Summary
+	}
+	return m
+}
+
+// utcTime returns t in UTC, or nil if t is the zero time.
+func utcTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// sortResults sorts results by path so that documents are reproducible
+// regardless of the order in which the workers finish.
+func sortResults(results []*Result) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+}
+
+// writeJSON writes a single JSON document with every match to w.
+func writeJSON(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	report := jsonReport{Matches: make([]*jsonMatch, 0)}
+	for _, r := range results {
+		path := r.displayPath(opts.FullPath)
+		for _, line := range r.Matches {
+			report.Matches = append(report.Matches, newJSONMatch(path, line))
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,190 @@
+package search
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mathpn/listme/pretty"
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`|`, `\|`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `&lt;`,
+	`>`, `&gt;`,
+	"\n", " ",
+)
+
+type markdownRow struct {
+	path string
+	line *Match
+}
+
+// markdownLink returns a link to the line, relative to the search root unless fullPath is set.
+func markdownLink(text, path string, line int) string {
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	target := strings.Join(segments, "/")
+	if line > 0 {
+		target = fmt.Sprintf("%s#L%d", target, line)
+	}
+	return fmt.Sprintf("[%s](%s)", markdownEscaper.Replace(text), target)
+}
+
+// markdownSummary formats tag counts in the same order as PrettySummary.
+func markdownSummary(counter map[string]int) string {
+	tags := make([]string, 0, len(counter))
+	for tag := range counter {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	parts := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		parts = append(parts, fmt.Sprintf("%s %d", pretty.Emojify(tag), counter[tag]))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func markdownCount(n int) string {
+	if n == 1 {
+		return "1 comment"
+	}
+	return fmt.Sprintf("%d comments", n)
+}
+
+func markdownAuthor(l *Match, opts *RenderOptions) string {
+	if l.Blame == nil {
+		return ""
+	}
+	author := markdownEscaper.Replace(opts.displayBlame(l.Blame).Author)
+	if !l.Blame.Time.IsZero() && l.Blame.Time.Before(opts.OldCommitTime) {
+		author = "**OLD** " + author
+	}
+	return author
+}
+
+// markdownText returns the comment text preceded by the tag metadata, if any.
+func markdownText(l *Match) string {
+	text := strings.TrimSpace(l.Text)
+	if text == "" {
+		text = "_no comment_"
+	} else {
+		text = markdownEscaper.Replace(text)
+	}
+	if l.hasMetadata() {
+		return markdownEscaper.Replace(l.metadataLabel()) + " " + text
+	}
+	return text
+}
+
+// writeMarkdown writes a Markdown report to w. Matches are grouped by file,
+// or by tag when opts.GroupByTag is set, each group with a heading and a table.
+func writeMarkdown(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	bw := bufio.NewWriter(w)
+
+	total := make(map[string]int)
+	var nLines int
+	for _, r := range results {
+		for tag, count := range r.tagCounts() {
+			total[tag] += count
+		}
+		nLines += len(r.Matches)
+	}
+
+	showAuthor := false
+	for _, r := range results {
+		for _, line := range r.Matches {
+			showAuthor = showAuthor || line.Blame != nil
+		}
+	}
+
+	fmt.Fprintf(bw, "# listme report\n\n")
+	fmt.Fprintf(bw, "**%s** in %d file(s)", markdownCount(nLines), len(results))
+	if len(total) > 0 {
+		fmt.Fprintf(bw, ": %s", markdownSummary(total))
+	}
+	fmt.Fprintf(bw, "\n")
+
+	if opts.GroupByTag {
+		writeMarkdownByTag(bw, results, total, showAuthor, opts)
+	} else {
+		writeMarkdownByFile(bw, results, showAuthor, opts)
+	}
+	return bw.Flush()
+}
+
+func writeMarkdownByFile(w io.Writer, results []*Result, showAuthor bool, opts *RenderOptions) {
+	for _, r := range results {
+		path := r.displayPath(opts.FullPath)
+		fmt.Fprintf(w, "\n## %s (%s)\n\n", markdownLink(path, path, 0), markdownCount(len(r.Matches)))
+		if counter := r.tagCounts(); !opts.NoSummary && len(counter) > 1 {
+			fmt.Fprintf(w, "%s\n\n", markdownSummary(counter))
+		}
+
+		if showAuthor {
+			fmt.Fprintf(w, "| Line | Tag | Comment | Author |\n|---:|---|---|---|\n")
+		} else {
+			fmt.Fprintf(w, "| Line | Tag | Comment |\n|---:|---|---|\n")
+		}
+		for _, line := range r.Matches {
+			fmt.Fprintf(w, "| %s | %s | %s |", markdownLink(fmt.Sprint(line.Line), path, line.Line), line.Tag, markdownText(line))
+			if showAuthor {
+				fmt.Fprintf(w, " %s |", markdownAuthor(line, opts))
+			}
+			fmt.Fprintf(w, "\n")
+		}
+	}
+}
+
+func writeMarkdownByTag(
+	w io.Writer,
+	results []*Result,
+	total map[string]int,
+	showAuthor bool,
+	opts *RenderOptions,
+) {
+	rows := make(map[string][]markdownRow, len(total))
+	for _, r := range results {
+		path := r.displayPath(opts.FullPath)
+		for _, line := range r.Matches {
+			rows[line.Tag] = append(rows[line.Tag], markdownRow{path: path, line: line})
+		}
+	}
+
+	tags := make([]string, 0, len(rows))
+	for tag := range rows {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		fmt.Fprintf(w, "\n## %s (%s)\n\n", pretty.Emojify(tag), markdownCount(total[tag]))
+		if showAuthor {
+			fmt.Fprintf(w, "| Location | Comment | Author |\n|---|---|---|\n")
+		} else {
+			fmt.Fprintf(w, "| Location | Comment |\n|---|---|\n")
+		}
+		for _, row := range rows[tag] {
+			location := markdownLink(fmt.Sprintf("%s:%d", row.path, row.line.Line), row.path, row.line.Line)
+			fmt.Fprintf(w, "| %s | %s |", location, markdownText(row.line))
+			if showAuthor {
+				fmt.Fprintf(w, " %s |", markdownAuthor(row.line, opts))
+			}
+			fmt.Fprintf(w, "\n")
+		}
+	}
+}
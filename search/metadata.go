@@ -0,0 +1,141 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// metadataRegex matches the metadata written right after a tag, e.g. (alice), [#1234] or !!!.
+const metadataRegex = `((?:\([^)]*\)|\[[^\]]*\]|!+)*)`
+
+// dateLayout is the layout of due dates in tag metadata.
+const dateLayout = "2006-01-02"
+
+// ParseDueDate parses a due date in the YYYY-MM-DD format of tag metadata.
+func ParseDueDate(date string) (time.Time, error) {
+	due, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid due date %s: must be YYYY-MM-DD", date)
+	}
+	return due, nil
+}
+
+var metadataGroupRegex = regexp.MustCompile(`\(([^)]*)\)|\[([^\]]*)\]|(!+)`)
+var issueRegex = regexp.MustCompile(`^(?:#\d+|[A-Z][A-Z0-9]+-\d+)$`)
+var priorityRegex = regexp.MustCompile(`^[pP]\d$`)
+var dateRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// parseMetadata fills the metadata fields of the match from the text after its tag.
+// Each item in parentheses or brackets is, in order of precedence:
+//   - a due date: 2025-03-01, due 2025-03-01 or until 2025-03-01
+//   - an issue: #1234 or PROJ-1234
+//   - a priority: p1 or P1, also written as exclamation marks: TODO!!!
+//   - otherwise, the assignee: alice or @alice
+//
+// Items are separated by commas or spaces. Only the first item of each kind is kept.
+// Due dates that can't be parsed, such as 2025-13-45 or due tomorrow, are kept in
+// invalidDue so that they can be reported instead of never expiring.
+func (l *Match) parseMetadata(metadata string) {
+	for _, group := range metadataGroupRegex.FindAllStringSubmatch(metadata, -1) {
+		if group[3] != "" {
+			if l.Priority == "" {
+				l.Priority = group[3]
+			}
+			continue
+		}
+		for _, item := range strings.Split(group[1]+group[2], ",") {
+			item = strings.TrimSpace(item)
+			keyword, date, _ := strings.Cut(item, " ")
+			keyword = strings.ToLower(keyword)
+			if keyword == "due" || keyword == "until" {
+				l.parseDue(strings.TrimSpace(date))
+				continue
+			}
+			for _, word := range strings.Fields(item) {
+				l.parseMetadataItem(word)
+			}
+		}
+	}
+}
+
+func (l *Match) parseMetadataItem(item string) {
+	if dateRegex.MatchString(item) {
+		l.parseDue(item)
+		return
+	}
+	switch {
+	case issueRegex.MatchString(item):
+		if l.Issue == "" {
+			l.Issue = item
+		}
+	case priorityRegex.MatchString(item):
+		if l.Priority == "" {
+			l.Priority = strings.ToUpper(item)
+		}
+	case l.Assignee == "":
+		l.Assignee = strings.TrimPrefix(item, "@")
+	}
+}
+
+func (l *Match) parseDue(item string) {
+	if !l.Due.IsZero() || l.invalidDue != "" {
+		return
+	}
+	due, err := time.Parse(dateLayout, item)
+	if err != nil {
+		l.invalidDue = item
+		return
+	}
+	l.Due = due
+}
+
+// hasMetadata returns true if any metadata field is set.
+func (l *Match) hasMetadata() bool {
+	return l.Assignee != "" || l.Issue != "" || l.Priority != "" || !l.Due.IsZero()
+}
+
+// Label returns the tag followed by its metadata in a normalized form,
+// e.g. "TODO(alice, #1234, P1, due 2025-03-01)" or "FIXME!!!".
+func (l *Match) Label() string {
+	label := l.Tag
+	if strings.HasPrefix(l.Priority, "!") {
+		label += l.Priority
+	}
+	var items []string
+	if l.Assignee != "" {
+		items = append(items, l.Assignee)
+	}
+	if l.Issue != "" {
+		items = append(items, l.Issue)
+	}
+	if l.Priority != "" && !strings.HasPrefix(l.Priority, "!") {
+		items = append(items, l.Priority)
+	}
+	if !l.Due.IsZero() {
+		items = append(items, "due "+l.Due.Format(dateLayout))
+	}
+	if len(items) > 0 {
+		label += "(" + strings.Join(items, ", ") + ")"
+	}
+	return label
+}
+
+// metadataLabel returns the metadata part of Label, e.g. "(alice, #1234)".
+func (l *Match) metadataLabel() string {
+	return strings.TrimPrefix(l.Label(), l.Tag)
+}
+
+// dueDate returns the due date formatted as YYYY-MM-DD, or an empty string if there's none.
+func (l *Match) dueDate() string {
+	if l.Due.IsZero() {
+		return ""
+	}
+	return l.Due.Format(dateLayout)
+}
+
+// normalizeIssue removes the leading # of an issue, so that #1234 and 1234 are the same.
+func normalizeIssue(issue string) string {
+	return strings.ToUpper(strings.TrimPrefix(issue, "#"))
+}
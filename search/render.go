@@ -0,0 +1,375 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/mathpn/listme/blame"
+	"github.com/mathpn/listme/pretty"
+)
+
+// TextFormat is the default output format. It's rendered according to RenderOptions.Style.
+const TextFormat = "text"
+
+// Grouping of matches in report formats that support it
+const (
+	GroupByFile = "file"
+	GroupByTag  = "tag"
+)
+
+// Renderer writes search results in an output format. Begin is called once before
+// any result, File once for each file with matches, Match for each match of that file
+// and End once after all results.
+//
+// Files may arrive in any order. Renderers that write a single document should
+// collect the results and write them in End.
+type Renderer interface {
+	Begin() error
+	File(result *Result) error
+	Match(result *Result, match *Match) error
+	End() error
+}
+
+// BlameRenderer is implemented by renderers that decide whether they show Git blame
+// information. Git blame is slow, so it's skipped when UsesBlame returns false.
+// Renderers that don't implement it are always given blame information.
+type BlameRenderer interface {
+	Renderer
+	UsesBlame() bool
+}
+
+// usesBlame returns true if the renderer shows Git blame information.
+func usesBlame(renderer Renderer) bool {
+	if r, ok := renderer.(BlameRenderer); ok {
+		return r.UsesBlame()
+	}
+	return true
+}
+
+// FileListRenderer is implemented by renderers that list every scanned file. If
+// ListsAllFiles returns true, files without matches are rendered too, as results
+// without matches, e.g. as passing test cases.
+type FileListRenderer interface {
+	Renderer
+	ListsAllFiles() bool
+}
+
+// listsAllFiles returns true if the renderer lists files without matches.
+func listsAllFiles(renderer Renderer) bool {
+	if r, ok := renderer.(FileListRenderer); ok {
+		return r.ListsAllFiles()
+	}
+	return false
+}
+
+// RendererFactory creates a Renderer that writes to w.
+type RendererFactory func(w io.Writer, opts RenderOptions) Renderer
+
+// RenderOptions configures how results are rendered. Zero values select the defaults.
+//   - Style: style of the text format
+//   - Width: maximum line width of the text format (default: terminal width)
+//   - OldCommitTime: commits before this time are marked as old
+//   - FullPath: print absolute paths instead of paths relative to the searched folder
+//   - NoSummary: do not print the tag summary of each file
+//   - NoAuthor: do not print Git author information
+//   - ShowEmail: show the author email instead of the author name in the text, markdown and html formats
+//   - GroupByTag: group matches by tag instead of by file, if the format supports it
+//   - Columns: columns of the delimited formats (default: DefaultColumns)
+type RenderOptions struct {
+	OldCommitTime time.Time
+	Columns       []string
+	Style         pretty.Style
+	Width         int
+	FullPath      bool
+	NoSummary     bool
+	NoAuthor      bool
+	ShowEmail     bool
+	GroupByTag    bool
+}
+
+var renderers = map[string]RendererFactory{
+	TextFormat:    newTextRenderer,
+	"json":        newDocumentRenderer(writeJSON, withBlame),
+	"ndjson":      newNDJSONRenderer,
+	"sarif":       newDocumentRenderer(writeSARIF, withBlame),
+	"checkstyle":  newFileListRenderer(writeCheckstyle),
+	"junit":       newFileListRenderer(writeJUnit),
+	"html":        newDocumentRenderer(writeHTML, withBlame),
+	"markdown":    newDocumentRenderer(writeMarkdown, withBlame),
+	"csv":         newDocumentRenderer(writeCSV, columnsUseBlame),
+	"tsv":         newDocumentRenderer(writeTSV, columnsUseBlame),
+	"quickfix":    newLineRenderer((*Match).QuickfixRender),
+	"github":      newLineRenderer((*Match).GitHubRender),
+	"gitlab":      newDocumentRenderer(writeGitLab, withoutBlame),
+	"todotxt":     newDocumentRenderer(writeTodoTxt, withBlame),
+	"taskwarrior": newDocumentRenderer(writeTaskwarrior, withBlame),
+}
+
+func withBlame(opts *RenderOptions) bool {
+	return true
+}
+
+func withoutBlame(opts *RenderOptions) bool {
+	return false
+}
+
+// RegisterRenderer makes an output format available under name, replacing any
+// format with the same name. It's not safe for concurrent use and is meant to be
+// called from init functions.
+func RegisterRenderer(name string, factory RendererFactory) {
+	renderers[name] = factory
+}
+
+// Formats returns the names of all registered output formats, starting with TextFormat.
+func Formats() []string {
+	formats := make([]string, 0, len(renderers))
+	for format := range renderers {
+		if format != TextFormat {
+			formats = append(formats, format)
+		}
+	}
+	sort.Strings(formats)
+	return append([]string{TextFormat}, formats...)
+}
+
+// NewRenderer returns a Renderer for the named format that writes to w.
+func NewRenderer(format string, w io.Writer, opts RenderOptions) (Renderer, error) {
+	factory, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+	if len(opts.Columns) == 0 {
+		opts.Columns, _ = ParseColumns(DefaultColumns)
+	}
+	return factory(w, opts), nil
+}
+
+// Render renders all results, for example the ones returned by Run, with the renderer.
+func Render(renderer Renderer, results []*Result) error {
+	if err := renderer.Begin(); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := renderResult(renderer, result); err != nil {
+			return err
+		}
+	}
+	return renderer.End()
+}
+
+func renderResult(renderer Renderer, result *Result) error {
+	if err := renderer.File(result); err != nil {
+		return err
+	}
+	for _, match := range result.Matches {
+		if err := renderer.Match(result, match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// textRenderer writes the human-readable text format, or the plain format
+// when the style is PlainStyle.
+type textRenderer struct {
+	w             io.Writer
+	opts          RenderOptions
+	path          string
+	maxLineNumber int
+	files         int
+}
+
+func newTextRenderer(w io.Writer, opts RenderOptions) Renderer {
+	if opts.Width == 0 && opts.Style.IsTerminal() {
+		opts.Width = getLimitedWidth()
+	}
+	return &textRenderer{w: w, opts: opts}
+}
+
+// UsesBlame returns false for the plain style, which has no author information.
+func (t *textRenderer) UsesBlame() bool {
+	return t.opts.Style != pretty.PlainStyle
+}
+
+func (t *textRenderer) Begin() error {
+	return nil
+}
+
+func (t *textRenderer) File(result *Result) error {
+	t.path = result.displayPath(t.opts.FullPath)
+	if t.opts.Style == pretty.PlainStyle {
+		return nil
+	}
+
+	if t.files > 0 {
+		if _, err := fmt.Fprintln(t.w); err != nil {
+			return err
+		}
+	}
+	t.files++
+	t.maxLineNumber = result.maxLineNumber()
+	if _, err := fmt.Fprintln(t.w, pretty.PrettyFilename(t.path, len(result.Matches), t.opts.Style)); err != nil {
+		return err
+	}
+	if !t.opts.NoSummary {
+		return result.printSummary(t.w, t.opts.Style)
+	}
+	return nil
+}
+
+func (t *textRenderer) Match(result *Result, match *Match) error {
+	if t.opts.Style == pretty.PlainStyle {
+		return match.PlainRender(t.w, t.path)
+	}
+	if t.opts.ShowEmail {
+		shown := *match
+		shown.Blame = t.opts.displayBlame(match.Blame)
+		match = &shown
+	}
+	return match.Render(t.w, t.opts.Width, t.maxLineNumber, t.opts.OldCommitTime, !t.opts.NoAuthor, t.opts.Style)
+}
+
+// displayBlame returns the blame information shown next to matches, with the author
+// email in place of the author name if ShowEmail is set, truncated to blame.MaxAuthorLength.
+func (o *RenderOptions) displayBlame(b *blame.LineBlame) *blame.LineBlame {
+	if b == nil {
+		return nil
+	}
+	shown := *b
+	if o.ShowEmail && b.Email != "" {
+		shown.Author = b.Email
+	}
+	shown.Author = blame.TruncateName(shown.Author, blame.MaxAuthorLength)
+	return &shown
+}
+
+func (t *textRenderer) End() error {
+	if t.files > 0 {
+		_, err := fmt.Fprintln(t.w)
+		return err
+	}
+	return nil
+}
+
+// lineRenderer writes each match as soon as it arrives, using one of the
+// line-oriented render methods of Match. None of them shows blame information.
+type lineRenderer struct {
+	w      io.Writer
+	opts   RenderOptions
+	path   string
+	render func(*Match, io.Writer, string) error
+}
+
+func newLineRenderer(render func(*Match, io.Writer, string) error) RendererFactory {
+	return func(w io.Writer, opts RenderOptions) Renderer {
+		return &lineRenderer{w: w, opts: opts, render: render}
+	}
+}
+
+func (l *lineRenderer) UsesBlame() bool {
+	return false
+}
+
+func (l *lineRenderer) Begin() error {
+	return nil
+}
+
+func (l *lineRenderer) File(result *Result) error {
+	l.path = result.displayPath(l.opts.FullPath)
+	return nil
+}
+
+func (l *lineRenderer) Match(result *Result, match *Match) error {
+	return l.render(match, l.w, l.path)
+}
+
+func (l *lineRenderer) End() error {
+	return nil
+}
+
+// ndjsonRenderer writes one JSON object per match as soon as it arrives.
+type ndjsonRenderer struct {
+	enc  *json.Encoder
+	opts RenderOptions
+	path string
+}
+
+func newNDJSONRenderer(w io.Writer, opts RenderOptions) Renderer {
+	return &ndjsonRenderer{enc: json.NewEncoder(w), opts: opts}
+}
+
+func (n *ndjsonRenderer) Begin() error {
+	return nil
+}
+
+func (n *ndjsonRenderer) File(result *Result) error {
+	n.path = result.displayPath(n.opts.FullPath)
+	return nil
+}
+
+func (n *ndjsonRenderer) Match(result *Result, match *Match) error {
+	return n.enc.Encode(newJSONMatch(n.path, match))
+}
+
+func (n *ndjsonRenderer) End() error {
+	return nil
+}
+
+// documentRenderer collects all results and writes them as a single document in End.
+// blame returns true if the document shows blame information with the options.
+type documentRenderer struct {
+	w       io.Writer
+	opts    RenderOptions
+	results []*Result
+	write   func(io.Writer, []*Result, *RenderOptions) error
+	blame   func(*RenderOptions) bool
+}
+
+func newDocumentRenderer(
+	write func(io.Writer, []*Result, *RenderOptions) error,
+	blame func(*RenderOptions) bool,
+) RendererFactory {
+	return func(w io.Writer, opts RenderOptions) Renderer {
+		return &documentRenderer{w: w, opts: opts, write: write, blame: blame}
+	}
+}
+
+func (d *documentRenderer) UsesBlame() bool {
+	return d.blame(&d.opts)
+}
+
+// fileListRenderer is a documentRenderer that is given every scanned file, including
+// files without matches. None of its formats shows blame information.
+type fileListRenderer struct {
+	*documentRenderer
+}
+
+func newFileListRenderer(write func(io.Writer, []*Result, *RenderOptions) error) RendererFactory {
+	return func(w io.Writer, opts RenderOptions) Renderer {
+		return fileListRenderer{&documentRenderer{w: w, opts: opts, write: write, blame: withoutBlame}}
+	}
+}
+
+func (f fileListRenderer) ListsAllFiles() bool {
+	return true
+}
+
+func (d *documentRenderer) Begin() error {
+	return nil
+}
+
+func (d *documentRenderer) File(result *Result) error {
+	d.results = append(d.results, result)
+	return nil
+}
+
+func (d *documentRenderer) Match(result *Result, match *Match) error {
+	return nil
+}
+
+func (d *documentRenderer) End() error {
+	return d.write(d.w, d.results, &d.opts)
+}
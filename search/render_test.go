@@ -0,0 +1,481 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathpn/listme/blame"
+	"github.com/mathpn/listme/pretty"
+)
+
+type countRenderer struct {
+	w              io.Writer
+	files, matches int
+}
+
+func (c *countRenderer) Begin() error { return nil }
+
+func (c *countRenderer) File(result *Result) error {
+	c.files++
+	return nil
+}
+
+func (c *countRenderer) Match(result *Result, match *Match) error {
+	c.matches++
+	return nil
+}
+
+func (c *countRenderer) End() error {
+	_, err := fmt.Fprintf(c.w, "%d files, %d matches\n", c.files, c.matches)
+	return err
+}
+
+var testResults = []*Result{
+	{rootPath: "/repo", Path: "/repo/b.go", Matches: []*Match{{Line: 2, Tag: "TODO", Text: " one"}}},
+	{rootPath: "/repo", Path: "/repo/a.go", Matches: []*Match{{Line: 1, Tag: "FIXME", Text: "two"}, {Line: 5, Tag: "NOTE", Text: ""}}},
+}
+
+// renderString renders results in the given format and returns the output.
+func renderString(t *testing.T, format string, results []*Result, opts RenderOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	renderer, err := NewRenderer(format, &buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(renderer, results); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestRegisterRenderer(t *testing.T) {
+	RegisterRenderer("count", func(w io.Writer, opts RenderOptions) Renderer {
+		return &countRenderer{w: w}
+	})
+	defer delete(renderers, "count")
+
+	var buf bytes.Buffer
+	renderer, err := NewRenderer("count", &buf, RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(renderer, testResults); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "2 files, 3 matches\n" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestPlainRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	renderer, err := NewRenderer(TextFormat, &buf, RenderOptions{Style: pretty.PlainStyle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(renderer, testResults); err != nil {
+		t.Fatal(err)
+	}
+	expected := "b.go:2:TODO: one\na.go:1:FIXME:two\na.go:5:NOTE:\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestJSONRenderer(t *testing.T) {
+	expected := `{
+  "matches": [
+    {
+      "path": "a.go",
+      "line": 1,
+      "end_line": 1,
+      "column": 0,
+      "tag": "FIXME",
+      "text": "two"
+    },
+    {
+      "path": "a.go",
+      "line": 5,
+      "end_line": 5,
+      "column": 0,
+      "tag": "NOTE",
+      "text": ""
+    },
+    {
+      "path": "b.go",
+      "line": 2,
+      "end_line": 2,
+      "column": 0,
+      "tag": "TODO",
+      "text": "one"
+    }
+  ]
+}
+`
+	if output := renderString(t, "json", testResults, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	if output := renderString(t, "json", nil, RenderOptions{}); output != "{\n  \"matches\": []\n}\n" {
+		t.Errorf("expected an empty list of matches, got:\n%s", output)
+	}
+}
+
+func TestNDJSONRenderer(t *testing.T) {
+	// matches are streamed in the order they are found, one object per line
+	expected := `{"path":"b.go","line":2,"end_line":2,"column":0,"tag":"TODO","text":"one"}
+{"path":"a.go","line":1,"end_line":1,"column":0,"tag":"FIXME","text":"two"}
+{"path":"a.go","line":5,"end_line":5,"column":0,"tag":"NOTE","text":""}
+`
+	if output := renderString(t, "ndjson", testResults, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+
+	blamed := []*Result{{rootPath: "/repo", Path: "/repo/c.go", Matches: []*Match{{
+		Line: 3, EndLine: 4, Column: 4, Tag: "TODO", Text: " three", Assignee: "alice",
+		Blame: &blame.LineBlame{Author: "alice", Email: "alice@example.com", Time: time.Unix(1700000000, 0), Commit: "abc123"},
+	}}}}
+	expected = `{"path":"c.go","line":3,"end_line":4,"column":4,"tag":"TODO","text":"three","assignee":"alice",` +
+		`"author":"alice","email":"alice@example.com","time":"2023-11-14T22:13:20Z","commit":"abc123"}` + "\n"
+	if output := renderString(t, "ndjson", blamed, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+}
+
+func TestSARIFRenderer(t *testing.T) {
+	var report sarifLog
+	if err := json.Unmarshal([]byte(renderString(t, "sarif", testResults, RenderOptions{})), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != "2.1.0" || len(report.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log: %+v", report)
+	}
+	run := report.Runs[0]
+	var rules []string
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.ID+":"+rule.DefaultConfiguration.Level)
+	}
+	if fmt.Sprint(rules) != "[FIXME:warning NOTE:note TODO:note]" {
+		t.Errorf("unexpected rules: %v", rules)
+	}
+
+	expected := []string{
+		"FIXME 0 warning a.go:1 two",
+		"NOTE 1 note a.go:5 NOTE comment",
+		"TODO 2 note b.go:2 one",
+	}
+	if len(run.Results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(run.Results))
+	}
+	for i, result := range run.Results {
+		location := result.Locations[0].PhysicalLocation
+		got := fmt.Sprintf(
+			"%s %d %s %s:%d %s",
+			result.RuleID, result.RuleIndex, result.Level,
+			location.ArtifactLocation.URI, location.Region.StartLine, result.Message.Text,
+		)
+		if got != expected[i] {
+			t.Errorf("result %d: expected %q, got %q", i, expected[i], got)
+		}
+		if location.ArtifactLocation.URIBaseID != "%SRCROOT%" {
+			t.Errorf("result %d: expected a path relative to %%SRCROOT%%", i)
+		}
+	}
+}
+
+func TestSARIFArtifact(t *testing.T) {
+	relative := sarifArtifact("dir/a b#1.go", false)
+	if relative.URI != "dir/a%20b%231.go" || relative.URIBaseID != "%SRCROOT%" {
+		t.Errorf("unexpected relative artifact: %+v", relative)
+	}
+	absolute := sarifArtifact("/repo/a b.go", true)
+	if absolute.URI != "file:///repo/a%20b.go" || absolute.URIBaseID != "" {
+		t.Errorf("unexpected absolute artifact: %+v", absolute)
+	}
+}
+
+// cleanResults are testResults plus a scanned file without tags, as returned with AllFiles.
+var cleanResults = append([]*Result{{rootPath: "/repo", Path: "/repo/c.go"}}, testResults...)
+
+func TestCheckstyleRenderer(t *testing.T) {
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+  <file name="a.go">
+    <error line="1" severity="warning" message="FIXME: two" source="listme.FIXME"></error>
+    <error line="5" severity="info" message="NOTE" source="listme.NOTE"></error>
+  </file>
+  <file name="b.go">
+    <error line="2" severity="info" message="TODO: one" source="listme.TODO"></error>
+  </file>
+  <file name="c.go"></file>
+</checkstyle>
+`
+	if output := renderString(t, "checkstyle", cleanResults, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+
+	special := []*Result{{rootPath: "/repo", Path: "/repo/d&e.go", Matches: []*Match{{Line: 1, Tag: "TODO", Text: ` <a> & "b"`}}}}
+	output := renderString(t, "checkstyle", special, RenderOptions{})
+	if !strings.Contains(output, `<file name="d&amp;e.go">`) ||
+		!strings.Contains(output, `message="TODO: &lt;a&gt; &amp; &#34;b&#34;"`) {
+		t.Errorf("expected escaped attributes, got:\n%s", output)
+	}
+}
+
+func TestJUnitRenderer(t *testing.T) {
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1">
+  <testsuite name="listme" tests="3" failures="1">
+    <testcase name="a.go" classname="listme">
+      <failure message="1 blocking comment(s)" type="FIXME">a.go:1: FIXME: two</failure>
+      <system-out>a.go:5: NOTE</system-out>
+    </testcase>
+    <testcase name="b.go" classname="listme">
+      <system-out>b.go:2: TODO: one</system-out>
+    </testcase>
+    <testcase name="c.go" classname="listme"></testcase>
+  </testsuite>
+</testsuites>
+`
+	if output := renderString(t, "junit", cleanResults, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	output := renderString(t, "html", testResults, RenderOptions{})
+	for _, expected := range []string{
+		"3 comment(s) in 2 file(s).",
+		`<td data-sort="a.go"><code>a.go</code></td><td class="count" data-sort="2">2</td>`,
+		`<td data-sort="b.go"><code>b.go</code></td><td class="count" data-sort="1">1</td>`,
+		`<span class="tag tag-FIXME">⚠ FIXME</span></td><td>two</td>`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, output)
+		}
+	}
+	if strings.Index(output, "<code>a.go</code></td><td class=\"line\"") > strings.Index(output, "<code>b.go</code></td><td class=\"line\"") {
+		t.Error("expected comments sorted by path")
+	}
+
+	special := []*Result{{rootPath: "/repo", Path: "/repo/x<&>.go", Matches: []*Match{{
+		Line: 1, Tag: "TODO", Text: ` <script>alert("x")</script> & more`,
+		Blame: &blame.LineBlame{Author: "Al <i>", Time: time.Now()},
+	}}}}
+	output = renderString(t, "html", special, RenderOptions{})
+	if strings.Contains(output, "<script>alert") || strings.Contains(output, "<i>") {
+		t.Errorf("unescaped comment or author in output:\n%s", output)
+	}
+	for _, expected := range []string{
+		"<code>x&lt;&amp;&gt;.go</code>",
+		"<td>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; more</td>",
+		`<td data-sort="Al &lt;i&gt;">Al &lt;i&gt;</td>`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	expected := `# listme report
+
+**3 comments** in 2 file(s): ⚠ FIXME 1 · ✐ NOTE 1 · ✓ TODO 1
+
+## [a.go](a.go) (2 comments)
+
+⚠ FIXME 1 · ✐ NOTE 1
+
+| Line | Tag | Comment |
+|---:|---|---|
+| [1](a.go#L1) | FIXME | two |
+| [5](a.go#L5) | NOTE | _no comment_ |
+
+## [b.go](b.go) (1 comment)
+
+| Line | Tag | Comment |
+|---:|---|---|
+| [2](b.go#L2) | TODO | one |
+`
+	if output := renderString(t, "markdown", testResults, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+
+	special := []*Result{{rootPath: "/repo", Path: "/repo/a b|c.go", Matches: []*Match{{
+		Line: 3, Tag: "TODO", Text: " use a|b, *not* [this](x) <br>",
+		Blame: &blame.LineBlame{Author: "Al_B", Time: time.Now()},
+	}}}}
+	output := renderString(t, "markdown", special, RenderOptions{})
+	for _, expected := range []string{
+		"## [a b\\|c.go](a%20b%7Cc.go) (1 comment)",
+		"| [3](a%20b%7Cc.go#L3) | TODO | use a\\|b, \\*not\\* \\[this\\](x) &lt;br&gt; | Al\\_B |",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestQuickfixRenderer(t *testing.T) {
+	expected := "b.go:2:0: TODO: one\na.go:1:0: FIXME: two\na.go:5:0: NOTE\n"
+	if output := renderString(t, "quickfix", testResults, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output: %q", output)
+	}
+
+	tagged := []*Result{{rootPath: "/repo", Path: "/repo/dir/c.go", Matches: []*Match{
+		{Line: 3, Column: 4, Tag: "TODO", Assignee: "alice", Issue: "#12", Text: " fix it  "},
+	}}}
+	expected = "dir/c.go:3:4: TODO(alice, #12): fix it\n"
+	if output := renderString(t, "quickfix", tagged, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output: %q", output)
+	}
+	expected = "/repo/dir/c.go:3:4: TODO(alice, #12): fix it\n"
+	if output := renderString(t, "quickfix", tagged, RenderOptions{FullPath: true}); output != expected {
+		t.Errorf("unexpected output with full paths: %q", output)
+	}
+}
+
+func TestGitHubRenderer(t *testing.T) {
+	expected := "::warning file=b.go,line=2,col=0,title=TODO::TODO: one\n" +
+		"::warning file=a.go,line=1,col=0,title=FIXME::FIXME: two\n" +
+		"::warning file=a.go,line=5,col=0,title=NOTE::NOTE\n"
+	if output := renderString(t, "github", testResults, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output: %q", output)
+	}
+
+	// properties also escape the , and : separators of the command
+	special := []*Result{{rootPath: "/repo", Path: "/repo/a,b:c%.go", Matches: []*Match{
+		{Line: 3, Column: 4, Tag: "TODO", Text: " 100% done: a, b\r\nnext"},
+	}}}
+	expected = "::warning file=a%2Cb%3Ac%25.go,line=3,col=4,title=TODO::TODO: 100%25 done: a, b%0D%0Anext\n"
+	if output := renderString(t, "github", special, RenderOptions{}); output != expected {
+		t.Errorf("unexpected output: %q", output)
+	}
+}
+
+// gitlabReport renders results in the gitlab format and decodes the issues.
+func gitlabReport(t *testing.T, results []*Result) []*gitlabIssue {
+	t.Helper()
+	var issues []*gitlabIssue
+	if err := json.Unmarshal([]byte(renderString(t, "gitlab", results, RenderOptions{})), &issues); err != nil {
+		t.Fatal(err)
+	}
+	return issues
+}
+
+func TestGitLabRenderer(t *testing.T) {
+	issues := gitlabReport(t, testResults)
+	expected := []string{
+		"a.go:1 FIXME major FIXME: two",
+		"a.go:5 NOTE info NOTE",
+		"b.go:2 TODO info TODO: one",
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %d", len(expected), len(issues))
+	}
+	fingerprints := make(map[string]bool)
+	for i, issue := range issues {
+		got := fmt.Sprintf(
+			"%s:%d %s %s %s",
+			issue.Location.Path, issue.Location.Lines.Begin, issue.CheckName, issue.Severity, issue.Description,
+		)
+		if got != expected[i] {
+			t.Errorf("issue %d: expected %q, got %q", i, expected[i], got)
+		}
+		if len(issue.Fingerprint) != 64 || fingerprints[issue.Fingerprint] {
+			t.Errorf("issue %d: invalid or duplicate fingerprint %s", i, issue.Fingerprint)
+		}
+		fingerprints[issue.Fingerprint] = true
+	}
+	if output := renderString(t, "gitlab", nil, RenderOptions{}); output != "[]\n" {
+		t.Errorf("expected an empty report, got %q", output)
+	}
+}
+
+func TestGitLabFingerprint(t *testing.T) {
+	file := func(offset int, last string) []*Result {
+		return []*Result{{rootPath: "/repo", Path: "/repo/a.go", Matches: []*Match{
+			{Line: 1 + offset, Tag: "TODO", Text: " same"},
+			{Line: 2 + offset, Tag: "TODO", Text: "same "},
+			{Line: 3 + offset, Tag: "FIXME", Text: last},
+		}}}
+	}
+	issues := gitlabReport(t, file(0, "last"))
+	if issues[0].Fingerprint == issues[1].Fingerprint {
+		t.Error("identical comments in the same file share a fingerprint")
+	}
+
+	moved := gitlabReport(t, file(10, "last"))
+	for i := range issues {
+		if moved[i].Fingerprint != issues[i].Fingerprint {
+			t.Errorf("issue %d: fingerprint changed when the comment moved to another line", i)
+		}
+	}
+
+	edited := gitlabReport(t, file(0, "edited"))
+	if edited[2].Fingerprint == issues[2].Fingerprint {
+		t.Error("fingerprint didn't change when the comment was edited")
+	}
+}
+
+func TestUnknownRenderer(t *testing.T) {
+	if _, err := NewRenderer("nope", io.Discard, RenderOptions{}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestUsesBlame(t *testing.T) {
+	tests := []struct {
+		format string
+		opts   RenderOptions
+		want   bool
+	}{
+		{TextFormat, RenderOptions{Style: pretty.BWStyle}, true},
+		{TextFormat, RenderOptions{Style: pretty.PlainStyle}, false},
+		{"json", RenderOptions{}, true},
+		{"quickfix", RenderOptions{}, false},
+		{"checkstyle", RenderOptions{}, false},
+		{"csv", RenderOptions{}, true},
+		{"csv", RenderOptions{Columns: []string{"path", "line", "tag"}}, false},
+	}
+	for _, tt := range tests {
+		renderer, err := NewRenderer(tt.format, io.Discard, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := usesBlame(renderer); got != tt.want {
+			t.Errorf("%s with %+v: expected %v, got %v", tt.format, tt.opts.Columns, tt.want, got)
+		}
+	}
+	if !usesBlame(&countRenderer{}) {
+		t.Error("expected blame for renderers that don't implement BlameRenderer")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestRenderWriteError(t *testing.T) {
+	for _, format := range Formats() {
+		renderer, err := NewRenderer(format, failingWriter{}, RenderOptions{Style: pretty.BWStyle, Width: 120})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Render(renderer, testResults); err == nil {
+			t.Errorf("%s: expected the write error to be returned", format)
+		}
+	}
+	renderer, _ := NewRenderer(TextFormat, failingWriter{}, RenderOptions{Style: pretty.PlainStyle})
+	if err := Render(renderer, testResults); err == nil {
+		t.Error("plain: expected the write error to be returned")
+	}
+}
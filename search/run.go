@@ -0,0 +1,207 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/mathpn/listme/blame"
+	"github.com/mathpn/listme/comment"
+	"github.com/mathpn/listme/matcher"
+)
+
+// DefaultTags are the tags searched for when none are specified.
+var DefaultTags = []string{"BUG", "FIXME", "XXX", "TODO", "HACK", "OPTIMIZE", "NOTE"}
+
+const defaultWorkers = 128
+const defaultMaxFileSize = 5
+
+// Options configures a search started with Run. Zero values select the defaults.
+//   - FS: filesystem to search instead of the OS filesystem, e.g. an embed.FS or a zip archive
+//   - Path: file or folder to search recursively (default: current directory), a slash-separated
+//     path of FS if it is set
+//   - Tags: tags to search for (default: DefaultTags)
+//   - Glob: glob pattern to filter file names (default: '*')
+//   - Author: keep only lines committed by this author
+//   - AuthorEmail: keep only lines committed by the author with this email, ignoring case.
+//     The email is mapped with the .mailmap of the repository
+//   - Since: keep only lines committed after this time
+//   - Assignee: keep only tags assigned to this person, e.g. TODO(alice), ignoring case
+//   - Issue: keep only tags referencing this issue, e.g. FIXME[#1234]
+//   - DueBefore: keep only tags with a due date before this time, e.g. TODO(2025-03-01)
+//   - CheckExpired: keep only tags whose due date has passed or is at most WarnDays days away,
+//     e.g. TODO(until 2025-06-30), and report the ones that have passed as ExpiredComments.
+//     Comments are valid until the end of their due date
+//   - MaxFileSize: maximum size of scanned files in MB (default: 5)
+//   - Workers: number of search workers (default: 128)
+//   - Blame: add Git blame information to every match
+//   - Blamer: source of Git blame information (default: blame.GitBlamer)
+//   - Languages: comment syntax of each language (default: the built-in languages)
+//   - AllFiles: also return the scanned text files without matches, as results without
+//     matches. Needed to render formats that list every file, see FileListRenderer
+//
+// Git blame is only available on the OS filesystem: Blame is ignored when FS is set,
+// and Author, AuthorEmail or Since are rejected.
+type Options struct {
+	Since        time.Time
+	DueBefore    time.Time
+	FS           fs.FS
+	Blamer       blame.Blamer
+	Languages    *comment.Registry
+	Path         string
+	Glob         string
+	Author       string
+	AuthorEmail  string
+	Assignee     string
+	Issue        string
+	Tags         []string
+	MaxFileSize  int64
+	Workers      int
+	WarnDays     int
+	Blame        bool
+	CheckExpired bool
+	AllFiles     bool
+}
+
+// params validates the options and converts them to searchParams.
+// Rendering fields are left unset.
+func (o Options) params() (*searchParams, error) {
+	path := o.Path
+	if path == "" {
+		path = "."
+	}
+	tags := o.Tags
+	if len(tags) == 0 {
+		tags = DefaultTags
+	}
+	glob := o.Glob
+	if glob == "" {
+		glob = "*"
+	}
+	workers := o.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	blamer := o.Blamer
+	if blamer == nil {
+		blamer = blame.NewGitBlamer()
+	}
+	languages := o.Languages
+	if languages == nil {
+		languages = comment.NewRegistry()
+	}
+	maxFileSize := o.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxFileSize
+	}
+
+	dueBefore := o.DueBefore
+	if o.CheckExpired {
+		warnDays := o.WarnDays
+		if warnDays < 0 {
+			warnDays = 0
+		}
+		limit := today(time.Now()).AddDate(0, 0, warnDays+1)
+		if dueBefore.IsZero() || limit.Before(dueBefore) {
+			dueBefore = limit
+		}
+	}
+
+	var rootPath string
+	var m matcher.Matcher
+	if o.FS != nil {
+		if o.Author != "" || o.AuthorEmail != "" || !o.Since.IsZero() {
+			return nil, fmt.Errorf("author and commit age filters require git blame, which is not available on an fs.FS")
+		}
+		rootPath = pathpkg.Clean(path)
+		if !fs.ValidPath(rootPath) {
+			return nil, fmt.Errorf("invalid path %s: must be an unrooted, slash-separated path", path)
+		}
+		m = matcher.NewMatcherFS(o.FS, rootPath, glob)
+	} else {
+		absPath, err := filepath.Abs(filepath.ToSlash(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path for %s: %s", path, err)
+		}
+		rootPath = absPath
+		m = matcher.NewMatcher(absPath, glob)
+	}
+
+	r, err := regexp.Compile(getTagRegex(tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile regex: %s", err)
+	}
+	commentRegex, err := regexp.Compile(getCommentTagRegex(tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile regex: %s", err)
+	}
+
+	commitAgeTime := zeroTime
+	if !o.Since.IsZero() {
+		commitAgeTime = o.Since
+	}
+
+	return &searchParams{
+		fsys:          o.FS,
+		blamer:        blamer,
+		rootPath:      rootPath,
+		regex:         r,
+		commentRegex:  commentRegex,
+		matcher:       m,
+		languages:     languages,
+		workers:       workers,
+		maxFs:         maxFileSize,
+		author:        o.Author,
+		authorEmail:   o.AuthorEmail,
+		assignee:      o.Assignee,
+		issue:         o.Issue,
+		dueBefore:     dueBefore,
+		commitAgeTime: commitAgeTime,
+		blame:         o.Blame,
+		checkExpired:  o.CheckExpired,
+		allFiles:      o.AllFiles,
+	}, nil
+}
+
+// Run searches a file or folder for tags and returns the results sorted by path,
+// without printing anything. Rendering the results is left to the caller.
+//
+// If ctx is cancelled, the search stops and the results found so far are
+// returned along with the context error. Files that can't be scanned are skipped
+// and reported as FileErrors, also along with the results of the other files.
+// Likewise, comments that expired in check mode are reported as ExpiredComments.
+func Run(ctx context.Context, opts Options) ([]*Result, error) {
+	params, err := opts.params()
+	if err != nil {
+		return nil, err
+	}
+
+	searchResults := make(chan *Result)
+	collected := make(chan []*Result)
+	go func() {
+		var results []*Result
+		for result := range searchResults {
+			results = append(results, result)
+		}
+		collected <- results
+	}()
+
+	err = run(ctx, params, searchResults)
+	close(searchResults)
+	results := <-collected
+	sortResults(results)
+	return results, err
+}
+
+// repoDir returns the directory of path, or path itself if it's a directory.
+func repoDir(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return path
+	}
+	return filepath.Dir(path)
+}
@@ -0,0 +1,387 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/mathpn/listme/blame"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.go":      "package b\n\n// TODO: first\nfunc b() {} // FIXME second\n",
+		"a.py":      "# NOTE: a note\n",
+		"empty.txt": "nothing to see here\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := Run(context.Background(), Options{Path: dir, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if filepath.Base(results[0].Path) != "a.py" || filepath.Base(results[1].Path) != "b.go" {
+		t.Errorf("results are not sorted by path: %s, %s", results[0].Path, results[1].Path)
+	}
+
+	matches := results[1].Matches
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches in b.go, got %d", len(matches))
+	}
+	if matches[0].Tag != "TODO" || matches[0].Line != 3 || strings.TrimSpace(matches[0].Text) != "first" {
+		t.Errorf("unexpected first match: %+v", matches[0])
+	}
+	if matches[1].Tag != "FIXME" || matches[1].Line != 4 || matches[1].Blame != nil {
+		t.Errorf("unexpected second match: %+v", matches[1])
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, Options{Path: t.TempDir()}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRunFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ok.go"), []byte("// TODO: keep going\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing.go"), filepath.Join(dir, "dangling.go")); err != nil {
+		t.Skipf("symlinks not supported: %s", err)
+	}
+
+	results, err := Run(context.Background(), Options{Path: dir})
+	var fileErrs FileErrors
+	if !errors.As(err, &fileErrs) {
+		t.Fatalf("expected FileErrors, got %v", err)
+	}
+	if len(fileErrs) != 1 || filepath.Base(fileErrs[0].Path) != "dangling.go" {
+		t.Errorf("unexpected file errors: %v", fileErrs)
+	}
+	if len(results) != 1 || filepath.Base(results[0].Path) != "ok.go" {
+		t.Errorf("expected results of the readable file, got %v", results)
+	}
+}
+
+func TestRunSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "a.go"), []byte("// TODO: once\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks not supported: %s", err)
+	}
+	if err := os.Symlink(filepath.Join("sub", "a.go"), filepath.Join(dir, "b.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	// symlinks to files are scanned, symlinks to directories are skipped
+	results, err := Run(context.Background(), Options{Path: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || filepath.Base(results[0].Path) != "b.go" || filepath.Base(results[1].Path) != "a.go" {
+		t.Errorf("expected b.go and sub/a.go, got %v", results)
+	}
+}
+
+func TestRunFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		".git/HEAD":          {Data: []byte("ref: refs/heads/main\n")},
+		".gitignore":         {Data: []byte("build/\n")},
+		"src/main.go":        {Data: []byte("package main\n\n// TODO: from fs\n")},
+		"build/generated.go": {Data: []byte("// TODO: ignored\n")},
+	}
+
+	results, err := Run(context.Background(), Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "src/main.go" {
+		t.Fatalf("expected only src/main.go, got %v", results)
+	}
+	if m := results[0].Matches; len(m) != 1 || m[0].Line != 3 || m[0].Blame != nil {
+		t.Errorf("unexpected matches: %+v", m)
+	}
+	if p := results[0].displayPath(false); p != "src/main.go" {
+		t.Errorf("unexpected display path %s", p)
+	}
+
+	if _, err := Run(context.Background(), Options{FS: fsys, Author: "alice"}); err == nil {
+		t.Error("expected an error for the author filter on an fs.FS")
+	}
+}
+
+type fakeBlamer map[int]*blame.LineBlame
+
+func (b fakeBlamer) BlameFile(ctx context.Context, repoDir string, path string, lines []int) (*blame.GitBlame, error) {
+	blames := make([]*blame.LineBlame, 10)
+	for line, lineBlame := range b {
+		blames[line-1] = lineBlame
+	}
+	return blame.NewGitBlame(blames), nil
+}
+
+func TestRunBlamer(t *testing.T) {
+	dir := t.TempDir()
+	content := "// TODO: mine\n// TODO: theirs\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	blamer := fakeBlamer{
+		1: {Author: "alice", Email: "alice@example.com", Time: time.Unix(1700000000, 0)},
+		2: {Author: "alice", Email: "alice@example.org", Time: time.Unix(1700000000, 0)},
+	}
+
+	results, err := Run(context.Background(), Options{Path: dir, Author: "alice", Blamer: blamer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 2 {
+		t.Fatalf("expected both lines of alice, got %v", results)
+	}
+
+	results, err = Run(context.Background(), Options{Path: dir, AuthorEmail: "Alice@Example.org", Blamer: blamer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 1 || results[0].Matches[0].Line != 2 {
+		t.Fatalf("expected only the line of alice@example.org, got %v", results)
+	}
+}
+
+// blockingBlamer blames b.go until ctx is done and every other file right away.
+type blockingBlamer struct {
+	blocked chan struct{}
+}
+
+func (b blockingBlamer) BlameFile(ctx context.Context, repoDir string, path string, lines []int) (*blame.GitBlame, error) {
+	if filepath.Base(path) == "b.go" {
+		close(b.blocked)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return blame.NewGitBlame([]*blame.LineBlame{{Author: "alice", Time: time.Unix(1700000000, 0)}}), nil
+}
+
+func TestRunCancelledBlame(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("// TODO: "+name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blamer := blockingBlamer{blocked: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type outcome struct {
+		results []*Result
+		err     error
+	}
+	done := make(chan outcome)
+	go func() {
+		// a single worker scans a.go before b.go
+		results, err := Run(ctx, Options{Path: dir, Blame: true, Blamer: blamer, Workers: 1})
+		done <- outcome{results, err}
+	}()
+
+	<-blamer.blocked
+	cancel()
+	select {
+	case out := <-done:
+		if !errors.Is(out.err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", out.err)
+		}
+		if len(out.results) != 1 || filepath.Base(out.results[0].Path) != "a.go" {
+			t.Fatalf("expected the result of a.go, got %v", out.results)
+		}
+		if out.results[0].Matches[0].Blame == nil {
+			t.Error("expected the blame of a.go")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
+
+func TestRunCommentSyntax(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go": {Data: []byte("package main\n\nvar TODO_LIST = \"TODO: not a comment\"\n\n/* FIXME: in a block */\n")},
+		"run.py":  {Data: []byte("x = '# TODO: not a comment'  # NOTE: a comment\n")},
+	}
+
+	results, err := Run(context.Background(), Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		if len(result.Matches) != 1 {
+			t.Fatalf("expected a single match in %s, got %d", result.Path, len(result.Matches))
+		}
+	}
+	if m := results[0].Matches[0]; m.Tag != "FIXME" || m.Line != 5 || m.Column != 4 {
+		t.Errorf("unexpected match in main.go: %+v", m)
+	}
+	if m := results[1].Matches[0]; m.Tag != "NOTE" || m.Column != 32 {
+		t.Errorf("unexpected match in run.py: %+v", m)
+	}
+}
+
+func TestRunMultilineComments(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go": {Data: []byte("/*\n * TODO: first line\n * second line\n *\n * unrelated\n */\n// FIXME: one\n//   two\n// three\n")},
+	}
+
+	results, err := Run(context.Background(), Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", results)
+	}
+	todo, fixme := results[0].Matches[0], results[0].Matches[1]
+	if todo.Line != 2 || todo.EndLine != 3 || strings.TrimSpace(todo.Text) != "first line second line" {
+		t.Errorf("unexpected block comment match: %+v", todo)
+	}
+	if fixme.Line != 7 || fixme.EndLine != 8 || strings.TrimSpace(fixme.Text) != "one two" {
+		t.Errorf("unexpected line comment match: %+v", fixme)
+	}
+}
+
+func TestRunMetadata(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go": {Data: []byte("// TODO(@alice, p1): first\n// FIXME[#1234]: second\n// TODO(2025-03-01) third\n// XXX!!!\n")},
+	}
+
+	results, err := Run(context.Background(), Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 4 {
+		t.Fatalf("expected 4 matches, got %v", results)
+	}
+	labels := []string{"TODO(alice, P1)", "FIXME(#1234)", "TODO(due 2025-03-01)", "XXX!!!"}
+	texts := []string{"first", "second", "third", ""}
+	for i, m := range results[0].Matches {
+		if m.Label() != labels[i] || strings.TrimSpace(m.Text) != texts[i] {
+			t.Errorf("unexpected match %d: %s %q", i, m.Label(), m.Text)
+		}
+	}
+
+	due := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	filters := []Options{{FS: fsys, Assignee: "ALICE"}, {FS: fsys, Issue: "1234"}, {FS: fsys, DueBefore: due}}
+	for i, opts := range filters {
+		results, err := Run(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || len(results[0].Matches) != 1 || results[0].Matches[0].Line != i+1 {
+			t.Errorf("unexpected results with filter %+v: %v", opts, results)
+		}
+	}
+}
+
+func TestRunCheckExpired(t *testing.T) {
+	now := time.Now()
+	date := func(days int) string { return now.AddDate(0, 0, days).Format(dateLayout) }
+	fsys := fstest.MapFS{
+		"main.go": {Data: []byte("// TODO(until " + date(-3) + "): expired\n" +
+			"// HACK(" + date(0) + "): due today\n" +
+			"// FIXME(due " + date(5) + "): due soon\n" +
+			"// TODO(" + date(30) + "): due later\n" +
+			"// NOTE: no due date\n")},
+	}
+
+	results, err := Run(context.Background(), Options{FS: fsys, CheckExpired: true, WarnDays: 7})
+	var expired ExpiredComments
+	if !errors.As(err, &expired) {
+		t.Fatalf("expected ExpiredComments, got %v", err)
+	}
+	if len(expired) != 1 || expired[0].Path != "main.go" || expired[0].Match.Line != 1 {
+		t.Errorf("unexpected expired comments: %v", expired)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 3 {
+		t.Fatalf("expected the expired and due soon comments, got %v", results)
+	}
+
+	_, err = Run(context.Background(), Options{FS: fsys, CheckExpired: true, Tags: []string{"FIXME", "NOTE"}})
+	if err != nil {
+		t.Errorf("expected no error without expired comments, got %v", err)
+	}
+}
+
+func TestRunInvalidDueDate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go": {Data: []byte("// TODO(2020-13-45): typo\n// HACK(until tomorrow): not a date\n")},
+	}
+
+	results, err := Run(context.Background(), Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", results)
+	}
+	if m := results[0].Matches[0]; m.Assignee != "" || !m.Due.IsZero() {
+		t.Errorf("expected an invalid date to be neither an assignee nor a due date: %+v", m)
+	}
+
+	_, err = Run(context.Background(), Options{FS: fsys, CheckExpired: true})
+	var expired ExpiredComments
+	if !errors.As(err, &expired) || len(expired) != 2 {
+		t.Fatalf("expected invalid due dates to fail the check, got %v", err)
+	}
+	if !strings.Contains(expired[0].Error(), "invalid due date 2020-13-45") {
+		t.Errorf("unexpected error: %s", expired[0])
+	}
+}
+
+func TestRunAllFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"clean.go":  {Data: []byte("package main\n")},
+		"main.go":   {Data: []byte("// FIXME: broken\n")},
+		"image.png": {Data: []byte("\x89PNG\r\n\x1a\n\x00\x00")},
+	}
+
+	results, err := Run(context.Background(), Options{FS: fsys, AllFiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "clean.go" || len(results[0].Matches) != 0 {
+		t.Fatalf("expected the clean text file without matches, got %v", results)
+	}
+
+	var buf strings.Builder
+	renderer, err := NewRenderer("junit", &buf, RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(renderer, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `tests="2" failures="1"`) {
+		t.Errorf("expected a passing test case for the clean file, got %s", buf.String())
+	}
+}
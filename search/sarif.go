@@ -0,0 +1,198 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+const sarifVersion = "2.1.0"
+const toolURI = "https://github.com/mathpn/listme"
+
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool      `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string           `json:"ruleId"`
+	RuleIndex  int              `json:"ruleIndex"`
+	Level      string           `json:"level"`
+	Message    sarifMessage     `json:"message"`
+	Locations  []*sarifLocation `json:"locations"`
+	Properties *sarifProperties `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+type sarifProperties struct {
+	Assignee   string     `json:"assignee,omitempty"`
+	Issue      string     `json:"issue,omitempty"`
+	Priority   string     `json:"priority,omitempty"`
+	Due        string     `json:"due,omitempty"`
+	Author     string     `json:"author,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	Commit     string     `json:"commit,omitempty"`
+	CommitTime *time.Time `json:"commitTime,omitempty"`
+}
+
+// sarifLevel maps a tag to a SARIF result level: informational tags are notes and
+// all other tags, BUG included, are warnings. The error level is not used because
+// GitHub code scanning fails pull request checks on error results by default, and
+// a comment shouldn't block a merge.
+func sarifLevel(tag string) string {
+	if tagSeverity(tag) == severityInfo {
+		return "note"
+	}
+	return "warning"
+}
+
+// sarifArtifact returns the location of a file as a URI, either absolute or relative
+// to the searched folder. Characters that aren't allowed in URIs are escaped.
+func sarifArtifact(path string, fullPath bool) sarifArtifactLocation {
+	if fullPath {
+		u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+		return sarifArtifactLocation{URI: u.String()}
+	}
+	u := url.URL{Path: filepath.ToSlash(path)}
+	return sarifArtifactLocation{URI: u.String(), URIBaseID: "%SRCROOT%"}
+}
+
+// writeSARIF writes a SARIF 2.1.0 log to w. Each tag is a rule and each matching
+// line is a result located at its file and line.
+func writeSARIF(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+
+	tagSet := make(map[string]bool)
+	for _, r := range results {
+		for _, line := range r.Matches {
+			tagSet[line.Tag] = true
+		}
+	}
+	tags := make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	rules := make([]*sarifRule, 0, len(tags))
+	ruleIndex := make(map[string]int, len(tags))
+	for i, tag := range tags {
+		ruleIndex[tag] = i
+		rules = append(rules, &sarifRule{
+			ID:                   tag,
+			Name:                 tag,
+			ShortDescription:     sarifMessage{Text: fmt.Sprintf("%s comment", tag)},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(tag)},
+		})
+	}
+
+	sarifResults := make([]*sarifResult, 0)
+	for _, r := range results {
+		artifact := sarifArtifact(r.displayPath(opts.FullPath), opts.FullPath)
+		for _, line := range r.Matches {
+			text := strings.TrimSpace(line.Text)
+			if text == "" {
+				text = fmt.Sprintf("%s comment", line.Tag)
+			}
+			result := &sarifResult{
+				RuleID:    line.Tag,
+				RuleIndex: ruleIndex[line.Tag],
+				Level:     sarifLevel(line.Tag),
+				Message:   sarifMessage{Text: text},
+				Locations: []*sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: artifact,
+						Region:           sarifRegion{StartLine: line.Line, EndLine: line.lastLine()},
+					},
+				}},
+			}
+			if line.Blame != nil || line.hasMetadata() {
+				result.Properties = &sarifProperties{
+					Assignee: line.Assignee,
+					Issue:    line.Issue,
+					Priority: line.Priority,
+					Due:      line.dueDate(),
+				}
+			}
+			if line.Blame != nil {
+				result.Properties.Author = line.Blame.Author
+				result.Properties.Email = line.Blame.Email
+				result.Properties.Commit = line.Blame.Commit
+				result.Properties.CommitTime = utcTime(line.Blame.Time)
+			}
+			sarifResults = append(sarifResults, result)
+		}
+	}
+
+	report := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []*sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "listme",
+				InformationURI: toolURI,
+				Rules:          rules,
+			}},
+			Results: sarifResults,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -2,7 +2,10 @@ package search
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
 	"os"
@@ -18,6 +21,7 @@ import (
 	logging "github.com/op/go-logging"
 
 	"github.com/mathpn/listme/blame"
+	"github.com/mathpn/listme/comment"
 	"github.com/mathpn/listme/matcher"
 	"github.com/mathpn/listme/pretty"
 )
@@ -32,75 +36,57 @@ const defaultWidth = 75
 const noComment = "\x1b[3m[no comment]\x1b[23m" // italic
 
 type searchParams struct {
-	oldCommitTime time.Time
+	render        RenderOptions
 	commitAgeTime time.Time
+	fsys          fs.FS
+	blamer        blame.Blamer
 	matcher       matcher.Matcher
+	languages     *comment.Registry
 	regex         *regexp.Regexp
+	commentRegex  *regexp.Regexp
 	rootPath      string
+	dueBefore     time.Time
 	author        string
-	style         pretty.Style
+	authorEmail   string
+	assignee      string
+	issue         string
+	format        string
 	workers       int
 	maxFs         int64
-	fullPath      bool
-	summary       bool
-	showAuthor    bool
+	blame         bool
+	checkExpired  bool
+	allFiles      bool
 }
 
 // NewSearchParams creates a searchParams struct with all the information required
-// to inspect a file or directory.
-func NewSearchParams(
-	path string,
-	tags []string,
-	workers int,
-	style pretty.Style,
-	oldCommitLimit, commitAgeFilter int,
-	maxFileSize int64,
-	fullPath, noSummary, noAuthor bool,
-	glob, author string,
-) (*searchParams, error) {
-	absPath, err := filepath.Abs(filepath.ToSlash(path))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path for %s: %s", path, err)
+// to inspect a file or directory as configured by opts and render the results in
+// the given format.
+func NewSearchParams(format string, opts Options, render RenderOptions) (*searchParams, error) {
+	if _, ok := renderers[format]; !ok {
+		return nil, fmt.Errorf("unknown output format: %s", format)
 	}
-
-	matcher := matcher.NewMatcher(absPath, glob)
-	regex := getTagRegex(tags)
-
-	r, err := regexp.Compile(regex)
+	params, err := opts.params()
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile regex: %s", err)
-	}
-
-	currentTime := time.Now()
-	maxAge := time.Duration(oldCommitLimit) * 24 * time.Hour
-	oldCommitTime := currentTime.Add(-maxAge)
-
-	commitAgeTime := zeroTime
-	if commitAgeFilter != -1 {
-		maxAge = time.Duration(commitAgeFilter) * 24 * time.Hour
-		commitAgeTime = currentTime.Add(-maxAge)
+		return nil, err
 	}
+	params.format = format
+	params.render = render
+	return params, nil
+}
 
-	return &searchParams{
-		rootPath:      absPath,
-		regex:         r,
-		matcher:       matcher,
-		workers:       workers,
-		style:         style,
-		oldCommitTime: oldCommitTime,
-		maxFs:         maxFileSize,
-		fullPath:      fullPath,
-		summary:       !noSummary,
-		showAuthor:    !noAuthor,
-		author:        author,
-		commitAgeTime: commitAgeTime,
-	}, nil
+// getCommentTagRegex returns a regex that finds tags in the text of a comment
+// found by a comment.Lexer.
+func getCommentTagRegex(tags []string) string {
+	return fmt.Sprintf(`\b(%s)%s(?:[\s:;-]|$)(.*)$`, strings.Join(tags, "|"), metadataRegex)
 }
 
+// getTagRegex returns a regex that finds tags in comments of any language.
+// It's used for files whose language is unknown.
 func getTagRegex(tags []string) string {
 	tagsRegex := fmt.Sprintf(
-		`(?m)(?:^|\s*(?:(?:#+|//+|<!--|--|/*|"""|''')+\s*)+)\s*(?:^|\b)(%s)(?:[\s:;-]|$)(.*?)(?:$|-->|#}}|\*/|--}}|}}|#+|#}|"""|''')*$`,
+		`(?m)(?:^|\s*(?:(?:#+|//+|<!--|--|/*|"""|''')+\s*)+)\s*(?:^|\b)(%s)%s(?:[\s:;-]|$)(.*?)(?:$|-->|#}}|\*/|--}}|}}|#+|#}|"""|''')*$`,
 		strings.Join(tags, "|"),
+		metadataRegex,
 	)
 	return tagsRegex
 }
@@ -110,11 +96,36 @@ type searchJob struct {
 	path  string
 }
 
-type matchLine struct {
-	blame *blame.LineBlame
-	tag   string
-	text  string
-	n     int
+// Match is a comment with one of the searched tags.
+//   - Line: 1-based line number where the tag is
+//   - EndLine: last line of the comment, if it continues on the following lines
+//   - Column: 1-based byte column where the tag starts
+//   - Tag: the matched tag, e.g. TODO
+//   - Text: comment text after the tag, with continuation lines joined by spaces
+//   - Assignee, Issue, Priority, Due: metadata written after the tag, see parseMetadata
+//   - Blame: Git blame information of Line, nil if not available or not requested
+type Match struct {
+	Due      time.Time
+	Blame    *blame.LineBlame
+	Tag      string
+	Text     string
+	Assignee string
+	Issue    string
+	Priority string
+	Line     int
+	EndLine  int
+	Column   int
+
+	// due date that couldn't be parsed, see parseMetadata
+	invalidDue string
+}
+
+// lastLine returns the last line of the comment.
+func (l *Match) lastLine() int {
+	if l.EndLine > l.Line {
+		return l.EndLine
+	}
+	return l.Line
 }
 
 // Wraps a long string on words with a max lineWidth.
@@ -161,134 +172,196 @@ func removeANSIEscapeCodes(input string) string {
 	return cleaned
 }
 
-// Render the line and print it to stdout using the provided style.
-// Depending on the width of the terminal, multiple lines may be printed.
-func (l *matchLine) Render(
+// Render the line and write it to w using the provided style.
+// Depending on the width of the terminal, multiple lines may be written.
+func (l *Match) Render(
+	w io.Writer,
 	width int,
 	maxLineNumber int,
 	oldCommitTime time.Time,
 	showAuthor bool,
 	style pretty.Style,
-) {
+) error {
 	maxDigits := len(fmt.Sprint(maxLineNumber))
 	lnSize := maxDigits + 9
 	maxTextWidth := width - lnSize - (blame.MaxAuthorLength + 7)
 
-	lenTag := len(l.tag) + 3
+	lenTag := len(l.Tag) + 3
 	if maxTextWidth < lenTag {
-		log.Fatal("terminal is too narrow")
+		return ErrNarrowTerminal
 	}
 
-	text := strings.TrimSpace(l.text)
+	text := strings.TrimSpace(l.Text)
 	if text == "" {
 		text = noComment
 	}
 
-	line := pretty.Bold(pretty.Emojify(l.tag)) + " " + text
+	line := pretty.Bold(pretty.Emojify(l.Tag)+l.metadataLabel()) + " " + text
 	wrapLine := wordWrap(line, maxTextWidth)
 	for i, chunk := range strings.Split(wrapLine, "\n") {
 		if i == 0 {
 			// Print lineNumber + tag + text + author info
 			cl := utf8.RuneCountInString(removeANSIEscapeCodes(chunk))
-			chunk = pretty.Colorize(chunk, l.tag, style)
-			lineNumber := pretty.PrettyLineNumber(l.n, maxDigits)
+			chunk = pretty.Colorize(chunk, l.Tag, style)
+			lineNumber := pretty.PrettyLineNumber(l.Line, maxDigits)
 			pad := strings.Repeat(" ", maxTextWidth-cl)
 			chunk = chunk + pad
 			var blameStr string
-			if showAuthor && l.blame != nil {
-				blameStr = " " + pretty.PrettyBlame(l.blame, oldCommitTime, style)
+			if showAuthor && l.Blame != nil {
+				blameStr = " " + pretty.PrettyBlame(l.Blame, oldCommitTime, style)
+			}
+			if _, err := fmt.Fprintln(w, lineNumber+chunk+blameStr); err != nil {
+				return err
 			}
-			fmt.Println(lineNumber + chunk + blameStr)
 		} else {
 			// Print only the rest of the text
-			chunk = pretty.Colorize(chunk, l.tag, style)
+			chunk = pretty.Colorize(chunk, l.Tag, style)
 			lineNumber := strings.Repeat(" ", len(fmt.Sprint(maxLineNumber))+10)
-			fmt.Println(lineNumber + chunk)
+			if _, err := fmt.Fprintln(w, lineNumber+chunk); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
-// Render the line and print it to stdout using the plain style format.
-func (l *matchLine) PlainRender(path string) {
-	fmt.Printf("%s:%d:%s:%s\n", path, l.n, l.tag, l.text)
+// Render the line and write it to w using the plain style format.
+func (l *Match) PlainRender(w io.Writer, path string) error {
+	_, err := fmt.Fprintf(w, "%s:%d:%s:%s\n", path, l.Line, l.Label(), l.Text)
+	return err
 }
 
-type searchResult struct {
+// Render the line and write it to w in the file:line:col: TAG: text format
+// understood by editor quickfix lists and problem matchers.
+func (l *Match) QuickfixRender(w io.Writer, path string) error {
+	text := strings.TrimSpace(l.Text)
+	if text == "" {
+		_, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", path, l.Line, l.Column, l.Label())
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", path, l.Line, l.Column, l.Label(), text)
+	return err
+}
+
+// Result contains all matches of a file, in line order.
+// Path is the absolute path of the file, or its path in Options.FS.
+type Result struct {
 	rootPath string
-	path     string
-	lines    []*matchLine
+	Path     string
+	Matches  []*Match
 }
 
-func (r *searchResult) maxLineNumber() int {
+func (r *Result) maxLineNumber() int {
 	max := 0
-	for _, line := range r.lines {
-		if line.n > max {
-			max = line.n
+	for _, line := range r.Matches {
+		if line.Line > max {
+			max = line.Line
 		}
 	}
 	return max
 }
 
-func (r *searchResult) printSummary(style pretty.Style) {
+// tagCounts returns the number of matching lines for each tag.
+func (r *Result) tagCounts() map[string]int {
 	counter := make(map[string]int, 10)
-	for i := 0; i < len(r.lines); i++ {
-		counter[r.lines[i].tag]++
+	for i := 0; i < len(r.Matches); i++ {
+		counter[r.Matches[i].Tag]++
 	}
+	return counter
+}
+
+func (r *Result) printSummary(w io.Writer, style pretty.Style) error {
+	counter := r.tagCounts()
 	if len(counter) < 2 {
-		return
+		return nil
 	}
-	fmt.Println(pretty.PrettySummary(counter, style))
+	_, err := fmt.Fprintln(w, pretty.PrettySummary(counter, style))
+	return err
 }
 
-// Render and print the filename and all matching lines to stdout.
-func (r *searchResult) Render(width int, params *searchParams) {
-	path := r.path
-	if !params.fullPath {
-		path = shortenFilepath(path, r.rootPath)
-	}
-	switch params.style {
-	case pretty.PlainStyle:
-		for _, line := range r.lines {
-			line.PlainRender(path)
-		}
-	default:
-		fmt.Println(pretty.PrettyFilename(path, len(r.lines), params.style))
-		if params.summary {
-			r.printSummary(params.style)
-		}
-		maxLineNumber := r.maxLineNumber()
-		for _, line := range r.lines {
-			line.Render(width, maxLineNumber, params.oldCommitTime, params.showAuthor, params.style)
-		}
-		fmt.Println()
+// displayPath returns the path that should be printed for the result.
+func (r *Result) displayPath(fullPath bool) string {
+	if fullPath {
+		return r.Path
 	}
+	return shortenFilepath(r.Path, r.rootPath)
 }
 
 func shortenFilepath(path string, rootPath string) string {
-	shortPath := strings.Trim(strings.Replace(path, rootPath, "", 1), string(os.PathSeparator))
+	if rootPath == "." {
+		// root of an fs.FS, paths are already relative
+		return path
+	}
+	shortPath := strings.Trim(strings.Replace(path, rootPath, "", 1), "/"+string(os.PathSeparator))
 	if shortPath == "" {
 		shortPath = filepath.Base(path)
 	}
 	return shortPath
 }
 
-// Search a file or folder for the specified tags.
+// Search a file or folder for the specified tags and print the results to stdout.
 // Use the function NewSearchParams to create the required struct.
-func Search(params *searchParams) {
+//
+// If ctx is cancelled, the search stops, in-flight git blame processes are killed
+// and the results found so far are printed before the context error is returned.
+// Files that can't be scanned are skipped and reported as FileErrors at the end.
+func Search(ctx context.Context, params *searchParams) error {
+	renderer, err := NewRenderer(params.format, os.Stdout, params.render)
+	if err != nil {
+		return err
+	}
+
+	// adapt a copy of the params to what the renderer shows
+	p := *params
+	if p.blame && !usesBlame(renderer) {
+		log.Debugf("skipping git blame: the %s format doesn't show it", p.format)
+		p.blame = false
+	}
+	p.allFiles = p.allFiles || listsAllFiles(renderer)
+	params = &p
+
+	searchResults := make(chan *Result)
+	printed := make(chan error)
+	go printResult(renderer, searchResults, printed)
+
+	err = run(ctx, params, searchResults)
+	close(searchResults)
+	return errors.Join(err, <-printed)
+}
+
+// run walks params.rootPath and sends the results of every file with matches to
+// searchResults. It returns once all files have been scanned. Files that can't be
+// read are skipped and returned as FileErrors, joined with the context error if any.
+// In check mode, comments whose due date has passed are returned as ExpiredComments.
+func run(ctx context.Context, params *searchParams, searchResults chan *Result) error {
+	if params.fsys == nil && params.authorEmail != "" {
+		// blame reports canonical emails, so the filter must use them too
+		p := *params
+		p.authorEmail = blame.CanonicalEmail(ctx, repoDir(p.rootPath), p.authorEmail)
+		params = &p
+	}
 	searchJobs := make(chan *searchJob)
-	searchResults := make(chan *searchResult)
+	errs := &errorCollector{}
+	expiry := newExpiryCollector(params)
 
 	var wg sync.WaitGroup
-	var wgResult sync.WaitGroup
 	for w := 0; w < params.workers; w++ {
-		go searchWorker(params, searchJobs, searchResults, &wg, &wgResult)
+		go searchWorker(ctx, params, searchJobs, searchResults, errs, expiry, &wg)
 	}
 
-	go printResult(searchResults, &wgResult, params)
-
 	walk := func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			log.Errorf("file walk error: %s", err)
+			if path == params.rootPath {
+				return err
+			}
+			errs.add(path, err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
@@ -314,9 +387,14 @@ func Search(params *searchParams) {
 			return nil
 		}
 
-		info, err := d.Info()
+		info, err := params.stat(path, d)
 		if err != nil {
-			log.Errorf("error getting file info for %s: %s", path, err)
+			errs.add(path, err)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			// e.g. symlinks to directories, which aren't followed, or named pipes
+			log.Infof("skipping %s: not a regular file", path)
 			return nil
 		}
 		if info.Size() > params.maxFs<<20 {
@@ -324,81 +402,156 @@ func Search(params *searchParams) {
 			return nil
 		}
 		wg.Add(1)
-		searchJobs <- &searchJob{regex: params.regex, path: path}
-		return nil
+		select {
+		case searchJobs <- &searchJob{regex: params.regex, path: path}:
+			return nil
+		case <-ctx.Done():
+			wg.Done()
+			return ctx.Err()
+		}
 	}
 
-	filepath.WalkDir(params.rootPath, walk)
+	err := params.walkDir(walk)
+	close(searchJobs)
 	wg.Wait()
-	wgResult.Wait()
+	if err == nil {
+		// the walk may finish before ctx is cancelled, while files are still scanned
+		err = ctx.Err()
+	}
+	return errors.Join(err, errs.err(), expiry.err())
+}
+
+// walkDir walks params.rootPath in params.fsys, or in the OS filesystem if it is nil.
+func (p *searchParams) walkDir(fn fs.WalkDirFunc) error {
+	if p.fsys == nil {
+		return filepath.WalkDir(p.rootPath, fn)
+	}
+	return fs.WalkDir(p.fsys, p.rootPath, fn)
+}
+
+// stat returns the file info of a walked entry, following symlinks.
+func (p *searchParams) stat(path string, d fs.DirEntry) (fs.FileInfo, error) {
+	if d.Type()&fs.ModeSymlink == 0 {
+		return d.Info()
+	}
+	if p.fsys == nil {
+		return os.Stat(filepath.FromSlash(path))
+	}
+	return fs.Stat(p.fsys, path)
+}
+
+// open opens a file from params.fsys, or from the OS filesystem if it is nil.
+func (p *searchParams) open(path string) (fs.File, error) {
+	if p.fsys == nil {
+		return os.Open(filepath.FromSlash(path))
+	}
+	return p.fsys.Open(path)
 }
 
 func searchWorker(
+	ctx context.Context,
 	params *searchParams,
 	jobs chan *searchJob,
-	searchResults chan *searchResult,
-	wg, wgResult *sync.WaitGroup,
+	searchResults chan *Result,
+	errs *errorCollector,
+	expiry *expiryCollector,
+	wg *sync.WaitGroup,
 ) {
 	for job := range jobs {
-		lines := scanFile(params, job)
-		if len(lines) > 0 {
-			wgResult.Add(1)
-			searchResults <- &searchResult{rootPath: params.rootPath, path: job.path, lines: lines}
+		lines, scanned, err := scanFile(ctx, params, job)
+		if err != nil {
+			errs.add(job.path, err)
+		}
+		if len(lines) > 0 || (scanned && params.allFiles) {
+			result := &Result{rootPath: params.rootPath, Path: job.path, Matches: lines}
+			expiry.add(result, params.render.FullPath)
+			searchResults <- result
 		}
 		wg.Done()
 	}
 }
 
+// scanFile returns the matching lines of a file and whether the whole file was scanned,
+// which is not the case for files that aren't text files. If ctx is cancelled before
+// the file is fully scanned, no lines are returned.
 func scanFile(
+	ctx context.Context,
 	params *searchParams,
 	job *searchJob,
-) []*matchLine {
+) ([]*Match, bool, error) {
+	if ctx.Err() != nil {
+		return nil, false, nil
+	}
 	log.Debugf("scanning file %s", job.path)
 
-	var lines []*matchLine
-	f, err := os.Open(filepath.FromSlash(job.path))
+	var lines []*Match
+	f, err := params.open(job.path)
 	if err != nil {
-		log.Fatalf("couldn't open path %s: %s", job.path, err)
-		return lines
+		return nil, false, err
 	}
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
+	var lexer *comment.Lexer
+	var pending *continuation
 
-	var gb *blame.GitBlame
-	var triedBlame bool
-	var lineBlame *blame.LineBlame
-
-	showAuthor := params.showAuthor && params.style != pretty.PlainStyle
-	requiresBlame := params.author != "" || !params.oldCommitTime.Equal(zeroTime) || showAuthor
+	// blame requires files on the OS filesystem
+	requiresBlame := params.fsys == nil &&
+		(params.blame || params.author != "" || params.authorEmail != "" || !params.commitAgeTime.Equal(zeroTime))
 
+	scanned := true
 	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if ctx.Err() != nil {
+			break
+		}
 		text := scanner.Bytes()
 
 		mimeType := http.DetectContentType(text)
 		if !strings.HasPrefix(strings.SplitN(mimeType, ";", 1)[0], "text") {
 			log.Infof("skipping non-text file of type %s: %s", mimeType, job.path)
+			scanned = false
 			break
 		}
 
-		match := job.regex.FindSubmatch(text)
-		if len(match) < 3 {
-			continue
+		if lineNumber == 1 {
+			if syntax := params.languages.Detect(job.path, text); syntax != nil {
+				log.Debugf("using %s comment syntax for %s", syntax.Name, job.path)
+				lexer = comment.NewLexer(syntax)
+			}
 		}
 
-		if requiresBlame && !triedBlame {
-			gb, _ = blame.BlameFile(job.path)
-			triedBlame = true
+		var line *Match
+		if lexer != nil {
+			segments := lexer.Next(text)
+			if pending != nil {
+				segments, pending = pending.extend(params.commentRegex, lexer, text, segments, lineNumber)
+			}
+			var next *continuation
+			if line, next = findCommentTag(params.commentRegex, lexer, text, segments); line != nil {
+				pending = next
+			}
+		} else {
+			line = findTag(job.regex, text)
 		}
-
-		if requiresBlame && gb != nil {
-			lineBlame, _ = gb.BlameLine(lineNumber)
+		if line != nil {
+			line.Line = lineNumber
+			line.EndLine = lineNumber
+			if line.invalidDue != "" {
+				log.Warningf("%s:%d: invalid due date %s: must be YYYY-MM-DD", job.path, lineNumber, line.invalidDue)
+			}
+			if validMetadata(job.path, line, params) {
+				lines = append(lines, line)
+			}
 		}
+	}
 
-		line := &matchLine{blame: lineBlame, n: lineNumber, tag: string(match[1]), text: string(match[2])}
-		if validLine(job.path, line, params) {
-			lines = append(lines, line)
-		}
+	if requiresBlame && len(lines) > 0 && ctx.Err() == nil {
+		lines = blameLines(ctx, params, job.path, lines)
+	}
+
+	if ctx.Err() != nil {
+		log.Debugf("discarding partial scan of %s: %s", job.path, ctx.Err())
+		return nil, false, nil
 	}
 
 	if err = scanner.Err(); err != nil {
@@ -409,25 +562,171 @@ func scanFile(
 				job.path,
 				bufio.MaxScanTokenSize>>10,
 			)
+			scanned = false
 		default:
-			log.Errorf("error while searching for tags in file %s - %s", job.path, err)
+			return lines, false, fmt.Errorf("error while searching for tags: %w", err)
 		}
 	}
-	return lines
+	return lines, scanned, nil
 }
 
-func validLine(path string, line *matchLine, params *searchParams) bool {
-	if params.author != "" && (line.blame == nil || line.blame.Author != params.author) {
-		log.Debugf("skipping %s line %d due to author filter", path, line.n)
+// findTag returns the first tag of a line of a file whose language is unknown, or nil.
+func findTag(regex *regexp.Regexp, text []byte) *Match {
+	match := regex.FindSubmatchIndex(text)
+	if len(match) < 8 || match[2] < 0 {
+		return nil
+	}
+	line := &Match{
+		Column: match[2] + 1,
+		Tag:    string(text[match[2]:match[3]]),
+		Text:   string(text[match[6]:match[7]]),
+	}
+	line.parseMetadata(string(text[match[4]:match[5]]))
+	return line
+}
+
+// findCommentTag returns the first tag in the comment segments of a line, or nil.
+// If the comment may continue on the next line, a continuation is returned as well.
+func findCommentTag(
+	regex *regexp.Regexp,
+	lexer *comment.Lexer,
+	text []byte,
+	segments []comment.Segment,
+) (*Match, *continuation) {
+	for _, segment := range segments {
+		match := regex.FindStringSubmatchIndex(segment.Text)
+		if match == nil {
+			continue
+		}
+		line := &Match{
+			Column: segment.Column + match[2],
+			Tag:    segment.Text[match[2]:match[3]],
+			Text:   segment.Text[match[6]:match[7]],
+		}
+		line.parseMetadata(segment.Text[match[4]:match[5]])
+		switch {
+		case segment.Block && segment.Open:
+			return line, &continuation{match: line, block: true}
+		case !segment.Block && lexer.IsLineComment(text[:segment.Column-1]):
+			return line, &continuation{match: line, column: segment.Column, indent: indentation(segment.Text)}
+		}
+		return line, nil
+	}
+	return nil, nil
+}
+
+// continuation is a match whose comment may continue on the next line, either because
+// it's in a block comment that is still open or because it's in a line comment that
+// may be followed by more indented line comments:
+//
+//	# TODO: a long comment
+//	#   continued here
+type continuation struct {
+	match  *Match
+	block  bool
+	column int
+	indent int
+}
+
+// extend adds the first comment segment of the next line to the match if it continues
+// the comment, stopping at blank lines and other tags. It returns the remaining segments
+// and the continuation, or nil if the comment can't continue further.
+func (c *continuation) extend(
+	regex *regexp.Regexp,
+	lexer *comment.Lexer,
+	text []byte,
+	segments []comment.Segment,
+	lineNumber int,
+) ([]comment.Segment, *continuation) {
+	if len(segments) == 0 || regex.MatchString(segments[0].Text) {
+		return segments, nil
+	}
+	segment := segments[0]
+
+	var body string
+	if c.block {
+		// remove decorations such as the leading * of Javadoc comments
+		body = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(segment.Text), "*"))
+		if !segment.Continued || body == "" {
+			return segments, nil
+		}
+	} else {
+		body = strings.TrimSpace(segment.Text)
+		if segment.Block || segment.Column != c.column || body == "" ||
+			indentation(segment.Text) <= c.indent || !lexer.IsLineComment(text[:segment.Column-1]) {
+			return segments, nil
+		}
+	}
+
+	c.match.Text = strings.TrimRight(c.match.Text, " \t") + " " + body
+	c.match.EndLine = lineNumber
+	if c.block && !segment.Open {
+		return segments[1:], nil
+	}
+	return segments[1:], c
+}
+
+// indentation returns the number of leading spaces and tabs of s.
+func indentation(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
+
+// blameLines adds Git blame information to the matching lines of a file with a
+// single blame call and returns the lines that pass the author and commit age filters.
+func blameLines(ctx context.Context, params *searchParams, path string, lines []*Match) []*Match {
+	lineNumbers := make([]int, len(lines))
+	for i, line := range lines {
+		lineNumbers[i] = line.Line
+	}
+	gb, _ := params.blamer.BlameFile(ctx, filepath.Dir(path), path, lineNumbers)
+
+	valid := lines[:0]
+	for _, line := range lines {
+		if gb != nil {
+			line.Blame, _ = gb.BlameLine(line.Line)
+		}
+		if validLine(path, line, params) {
+			valid = append(valid, line)
+		}
+	}
+	return valid
+}
+
+// validMetadata returns true if the line passes the assignee, issue and due date filters.
+func validMetadata(path string, line *Match, params *searchParams) bool {
+	if params.assignee != "" && !strings.EqualFold(line.Assignee, strings.TrimPrefix(params.assignee, "@")) {
+		log.Debugf("skipping %s line %d due to assignee filter", path, line.Line)
+		return false
+	}
+	if params.issue != "" && normalizeIssue(line.Issue) != normalizeIssue(params.issue) {
+		log.Debugf("skipping %s line %d due to issue filter", path, line.Line)
+		return false
+	}
+	// in check mode, invalid due dates are reported as expired
+	invalidDue := params.checkExpired && line.invalidDue != ""
+	if !params.dueBefore.IsZero() && !invalidDue && (line.Due.IsZero() || !line.Due.Before(params.dueBefore)) {
+		log.Debugf("skipping %s line %d due to due date filter", path, line.Line)
+		return false
+	}
+	return true
+}
+
+func validLine(path string, line *Match, params *searchParams) bool {
+	if params.author != "" && (line.Blame == nil || line.Blame.Author != params.author) {
+		log.Debugf("skipping %s line %d due to author filter", path, line.Line)
+		return false
+	}
+	if params.authorEmail != "" && (line.Blame == nil || !strings.EqualFold(line.Blame.Email, params.authorEmail)) {
+		log.Debugf("skipping %s line %d due to author email filter", path, line.Line)
 		return false
 	}
 	if !params.commitAgeTime.Equal(zeroTime) {
-		if line.blame == nil {
+		if line.Blame == nil {
 			log.Debugf("skipping %s line %d due to commit age: no git blame", path, line)
 			return false
 		}
 
-		if line.blame.Time.Before(params.commitAgeTime) {
+		if line.Blame.Time.Before(params.commitAgeTime) {
 			log.Debugf("skipping %s line %d due to commit age", path, line)
 			return false
 		}
@@ -435,15 +734,23 @@ func validLine(path string, line *matchLine, params *searchParams) bool {
 	return true
 }
 
-func printResult(searchResults chan *searchResult, wgResult *sync.WaitGroup, params *searchParams) {
-	var width int
-	if params.style != pretty.PlainStyle {
-		width = getLimitedWidth()
-	}
+// printResult renders results with the renderer as they arrive. After the first
+// rendering error, the remaining results are discarded. The error, if any, is sent
+// to printed once the renderer has finished.
+func printResult(renderer Renderer, searchResults chan *Result, printed chan error) {
+	err := renderer.Begin()
 	for result := range searchResults {
-		result.Render(width, params)
-		wgResult.Done()
+		if err == nil {
+			err = renderResult(renderer, result)
+		}
+	}
+	if err == nil {
+		err = renderer.End()
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to write output: %w", err)
 	}
+	printed <- err
 }
 
 func getWidth() int {
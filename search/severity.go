@@ -0,0 +1,23 @@
+package search
+
+// severity of a tag, used by report formats that classify their findings.
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityWarning
+	severityError
+)
+
+// tagSeverity returns the severity of a tag. Bugs are errors, FIXME and XXX
+// are warnings and every other tag, including custom ones, is informative.
+func tagSeverity(tag string) severity {
+	switch tag {
+	case "BUG":
+		return severityError
+	case "FIXME", "XXX":
+		return severityWarning
+	default:
+		return severityInfo
+	}
+}
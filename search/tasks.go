@@ -0,0 +1,188 @@
+package search
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// uuidNamespace is the RFC 4122 URL namespace. Task UUIDs are name-based (version 5)
+// UUIDs in this namespace, so the same comment always gets the same UUID.
+var uuidNamespace = [16]byte{0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+const taskwarriorTime = "20060102T150405Z"
+
+type taskwarriorTask struct {
+	UUID        string   `json:"uuid"`
+	Status      string   `json:"status"`
+	Description string   `json:"description"`
+	Entry       string   `json:"entry,omitempty"`
+	Project     string   `json:"project"`
+	Priority    string   `json:"priority,omitempty"`
+	Due         string   `json:"due,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// taskUUID returns a version 5 UUID derived from the path, tag and text of the comment.
+func taskUUID(path string, l *Match, occurrence int) string {
+	h := sha1.New()
+	h.Write(uuidNamespace[:])
+	h.Write([]byte(toolURI + "#" + matchKey(path, l, occurrence)))
+	u := h.Sum(nil)[:16]
+	u[6] = (u[6] & 0x0f) | 0x50 // version 5
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// taskWord replaces whitespace so that s can be used as a single word, e.g. in +project.
+func taskWord(s string) string {
+	return strings.Join(strings.Fields(s), "_")
+}
+
+// priorityRank returns the rank of the priority metadata of the comment, 1 being the
+// most urgent: p1 and !!! are 1, p2 and !! are 2, p3 and ! are 3 and so on.
+// It returns 0 if the comment has no priority.
+func priorityRank(l *Match) int {
+	var rank int
+	switch {
+	case l.Priority == "":
+		return 0
+	case strings.HasPrefix(l.Priority, "!"):
+		rank = 4 - len(l.Priority)
+	default:
+		rank = int(l.Priority[1] - '0')
+	}
+	if rank < 1 {
+		rank = 1
+	}
+	return rank
+}
+
+// todoTxtPriority returns the todo.txt priority of the comment: its priority
+// metadata if it has one, e.g. (A) for p1, otherwise the severity of its tag.
+func todoTxtPriority(l *Match) string {
+	if rank := priorityRank(l); rank > 0 {
+		return fmt.Sprintf("(%c) ", 'A'+rank-1)
+	}
+	switch tagSeverity(l.Tag) {
+	case severityError:
+		return "(A) "
+	case severityWarning:
+		return "(B) "
+	default:
+		return ""
+	}
+}
+
+// taskwarriorPriority returns the Taskwarrior priority of the comment: its priority
+// metadata if it has one, e.g. H for p1 and L for p3 or lower, otherwise the severity of its tag.
+func taskwarriorPriority(l *Match) string {
+	if rank := priorityRank(l); rank > 0 {
+		switch rank {
+		case 1:
+			return "H"
+		case 2:
+			return "M"
+		default:
+			return "L"
+		}
+	}
+	switch tagSeverity(l.Tag) {
+	case severityError:
+		return "H"
+	case severityWarning:
+		return "M"
+	default:
+		return ""
+	}
+}
+
+// todoTxtLine returns the todo.txt task for the comment. The tag is the context,
+// the author is the project and the commit date is the creation date. Tag metadata
+// is written as due, assignee and issue key-value pairs.
+func todoTxtLine(path string, l *Match, occurrence int) string {
+	var b strings.Builder
+	b.WriteString(todoTxtPriority(l))
+	if l.Blame != nil && !l.Blame.Time.IsZero() {
+		b.WriteString(l.Blame.Time.Format("2006-01-02") + " ")
+	}
+	text := strings.TrimSpace(l.Text)
+	if text == "" {
+		text = l.Tag
+	}
+	b.WriteString(strings.Join(strings.Fields(text), " "))
+	b.WriteString(" @" + taskWord(l.Tag))
+	if l.Blame != nil && l.Blame.Author != "" {
+		b.WriteString(" +" + taskWord(l.Blame.Author))
+	}
+	if due := l.dueDate(); due != "" {
+		b.WriteString(" due:" + due)
+	}
+	if l.Assignee != "" {
+		b.WriteString(" assignee:" + taskWord(l.Assignee))
+	}
+	if l.Issue != "" {
+		b.WriteString(" issue:" + taskWord(l.Issue))
+	}
+	fmt.Fprintf(&b, " file:%s line:%d", strings.ReplaceAll(filepath.ToSlash(path), " ", "%20"), l.Line)
+	b.WriteString(" uuid:" + taskUUID(path, l, occurrence))
+	return b.String()
+}
+
+func newTaskwarriorTask(path string, l *Match, occurrence int) *taskwarriorTask {
+	task := &taskwarriorTask{
+		UUID:        taskUUID(path, l, occurrence),
+		Status:      "pending",
+		Description: fmt.Sprintf("%s (%s:%d)", lineMessage(l), filepath.ToSlash(path), l.Line),
+		Project:     l.Tag,
+		Priority:    taskwarriorPriority(l),
+	}
+	if !l.Due.IsZero() {
+		task.Due = l.Due.UTC().Format(taskwarriorTime)
+	}
+	if l.Blame != nil {
+		if l.Blame.Author != "" {
+			task.Tags = []string{taskWord(l.Blame.Author)}
+		}
+		if !l.Blame.Time.IsZero() {
+			task.Entry = l.Blame.Time.UTC().Format(taskwarriorTime)
+		}
+	}
+	return task
+}
+
+// writeTodoTxt writes one todo.txt task per matching line to w.
+func writeTodoTxt(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	bw := bufio.NewWriter(w)
+	for _, r := range results {
+		path := r.displayPath(opts.FullPath)
+		occurrences := r.occurrences()
+		for i, line := range r.Matches {
+			fmt.Fprintln(bw, todoTxtLine(path, line, occurrences[i]))
+		}
+	}
+	return bw.Flush()
+}
+
+// writeTaskwarrior writes a JSON array of tasks to w that can be loaded with `task import`.
+// Tasks have stable UUIDs, so importing again updates them instead of creating duplicates.
+func writeTaskwarrior(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	tasks := make([]*taskwarriorTask, 0)
+	for _, r := range results {
+		path := r.displayPath(opts.FullPath)
+		occurrences := r.occurrences()
+		for i, line := range r.Matches {
+			tasks = append(tasks, newTaskwarriorTask(path, line, occurrences[i]))
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tasks)
+}
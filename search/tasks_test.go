@@ -0,0 +1,59 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestTaskUUID(t *testing.T) {
+	line := &Match{Line: 10, Tag: "TODO", Text: " write tests"}
+	moved := &Match{Line: 42, Tag: "TODO", Text: "write tests "}
+
+	uuid := taskUUID("main.go", line, 0)
+	if !uuidRegex.MatchString(uuid) {
+		t.Errorf("invalid version 5 UUID: %s", uuid)
+	}
+	if taskUUID("main.go", moved, 0) != uuid {
+		t.Error("UUID changed when the comment moved to another line")
+	}
+	if taskUUID("main.go", line, 1) == uuid {
+		t.Error("identical comments in the same file share a UUID")
+	}
+	if taskUUID("other.go", line, 0) == uuid {
+		t.Error("comments in different files share a UUID")
+	}
+}
+
+func TestTaskPriority(t *testing.T) {
+	tests := []struct {
+		tag, priority, todoTxt, taskwarrior string
+	}{
+		{"TODO", "", "", ""},
+		{"FIXME", "", "(B) ", "M"},
+		{"BUG", "", "(A) ", "H"},
+		{"TODO", "P1", "(A) ", "H"},
+		{"TODO", "!!!", "(A) ", "H"},
+		{"TODO", "P2", "(B) ", "M"},
+		{"TODO", "!!", "(B) ", "M"},
+		{"BUG", "!", "(C) ", "L"},
+		{"TODO", "P0", "(A) ", "H"},
+		{"TODO", "P5", "(E) ", "L"},
+	}
+	for _, tt := range tests {
+		line := &Match{Tag: tt.tag, Priority: tt.priority}
+		if p := todoTxtPriority(line); p != tt.todoTxt {
+			t.Errorf("%s %s: expected todo.txt priority %q, got %q", tt.tag, tt.priority, tt.todoTxt, p)
+		}
+		if p := taskwarriorPriority(line); p != tt.taskwarrior {
+			t.Errorf("%s %s: expected taskwarrior priority %q, got %q", tt.tag, tt.priority, tt.taskwarrior, p)
+		}
+	}
+
+	line := &Match{Line: 3, Tag: "TODO", Text: " fix", Assignee: "alice", Priority: "P1"}
+	if task := todoTxtLine("a.go", line, 0); !strings.HasPrefix(task, "(A) fix @TODO assignee:alice file:a.go line:3 ") {
+		t.Errorf("unexpected todo.txt task: %s", task)
+	}
+}
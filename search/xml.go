@@ -0,0 +1,163 @@
+package search
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const checkstyleVersion = "4.3"
+
+type checkstyleReport struct {
+	XMLName xml.Name          `xml:"checkstyle"`
+	Version string            `xml:"version,attr"`
+	Files   []*checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string             `xml:"name,attr"`
+	Errors []*checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type junitReport struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func checkstyleSeverity(tag string) string {
+	switch tagSeverity(tag) {
+	case severityError:
+		return "error"
+	case severityWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// lineMessage returns the tag and its metadata followed by the comment text,
+// e.g. "TODO(alice): add tests".
+func lineMessage(l *Match) string {
+	text := strings.TrimSpace(l.Text)
+	if text == "" {
+		return l.Label()
+	}
+	return l.Label() + ": " + text
+}
+
+// checkstyleFile returns the Checkstyle file element with one error per matching line.
+func (r *Result) checkstyleFile(path string) *checkstyleFile {
+	file := &checkstyleFile{Name: path}
+	for _, line := range r.Matches {
+		file.Errors = append(file.Errors, &checkstyleError{
+			Line:     line.Line,
+			Severity: checkstyleSeverity(line.Tag),
+			Message:  lineMessage(line),
+			Source:   "listme." + line.Tag,
+		})
+	}
+	return file
+}
+
+// junitTestCase returns a JUnit test case for the file. The test case fails
+// when the file contains blocking tags, that is, tags of warning severity or higher.
+func (r *Result) junitTestCase(path string) *junitTestCase {
+	testCase := &junitTestCase{Name: path, ClassName: "listme"}
+	var blocking, other []string
+	var blockingTags []string
+	seen := make(map[string]bool)
+	for _, line := range r.Matches {
+		msg := fmt.Sprintf("%s:%d: %s", path, line.Line, lineMessage(line))
+		if tagSeverity(line.Tag) == severityInfo {
+			other = append(other, msg)
+			continue
+		}
+		blocking = append(blocking, msg)
+		if !seen[line.Tag] {
+			seen[line.Tag] = true
+			blockingTags = append(blockingTags, line.Tag)
+		}
+	}
+
+	if len(blocking) > 0 {
+		testCase.Failure = &junitFailure{
+			Message: fmt.Sprintf("%d blocking comment(s)", len(blocking)),
+			Type:    strings.Join(blockingTags, ","),
+			Text:    strings.Join(blocking, "\n"),
+		}
+	}
+	if len(other) > 0 {
+		testCase.SystemOut = strings.Join(other, "\n")
+	}
+	return testCase
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeCheckstyle writes a Checkstyle XML report to w with one file element
+// per file and one error element per matching line.
+func writeCheckstyle(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	report := checkstyleReport{Version: checkstyleVersion}
+	for _, r := range results {
+		report.Files = append(report.Files, r.checkstyleFile(r.displayPath(opts.FullPath)))
+	}
+	return writeXML(w, report)
+}
+
+// writeJUnit writes a JUnit XML report to w with one test case per file.
+func writeJUnit(w io.Writer, results []*Result, opts *RenderOptions) error {
+	sortResults(results)
+	suite := &junitTestSuite{Name: "listme"}
+	for _, r := range results {
+		testCase := r.junitTestCase(r.displayPath(opts.FullPath))
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	report := junitReport{Tests: suite.Tests, Failures: suite.Failures, Suites: []*junitTestSuite{suite}}
+	return writeXML(w, report)
+}